@@ -2,14 +2,24 @@ package api
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
 
 	"github.com/txsvc/cloudlib/settings"
 	"github.com/txsvc/stdlib/v2"
 
+	"github.com/txsvc/apikit/auth"
 	"github.com/txsvc/apikit/config"
 )
 
@@ -27,6 +37,8 @@ var (
 
 	// ErrApiInvocationError indicates an error in an API call
 	ErrApiInvocationError = errors.New("api invocation error")
+	// ErrForeignOrigin indicates a URL with another scheme or host than the endpoint, the credentials are never sent to it
+	ErrForeignOrigin = errors.New("url does not belong to the endpoint")
 )
 
 // Client - API client encapsulating the http client
@@ -35,14 +47,34 @@ type (
 		httpClient *http.Client
 		ds         *settings.DialSettings
 		trace      string
+		// request signing instead of a bearer token
+		signed bool
+		// client certificates and trusted CAs
+		tlsConfig *tls.Config
+		// the versions reported with the last response, see VersionCheck
+		serverVersion    string
+		minClientVersion string
+		vmu              sync.Mutex // protects the versions
+		noVersionCheck   bool
+	}
+
+	// ClientOption configures a Client in NewClient
+	ClientOption func(*Client) error
+
+	// Error is returned if the API responds with a status other than OK, Created, Accepted or NoContent.
+	// It wraps ErrApiInvocationError.
+	Error struct {
+		Status    int    // the HTTP status code
+		Message   string // the message of the StatusObject in the response, if any
+		RequestID string // the X-Request-ID of the response, if any
 	}
 )
 
-func NewClient(ds *settings.DialSettings) *Client {
+// NewClient creates a client with the settings provided or the ones from the current config.
+// It returns nil if any of the options can not be applied.
+func NewClient(ds *settings.DialSettings, opts ...ClientOption) *Client {
 	var _ds *settings.DialSettings
 
-	httpClient := NewTransport(http.DefaultTransport)
-
 	// create or clone the settings
 	if ds != nil {
 		c := ds.Clone()
@@ -54,10 +86,81 @@ func NewClient(ds *settings.DialSettings) *Client {
 		}
 	}
 
-	return &Client{
-		httpClient: httpClient,
-		ds:         _ds,
-		trace:      stdlib.GetString(config.ForceTraceENV, ""),
+	c := &Client{
+		ds:    _ds,
+		trace: stdlib.GetString(config.ForceTraceENV, ""),
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil
+		}
+	}
+	if c.tlsConfig != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = c.tlsConfig
+		transport = t
+	}
+	if c.signed {
+		transport = NewSigningTransport(transport, c.ds.Credentials.Key(), auth.SigningSecret(c.ds.Credentials))
+	}
+	c.httpClient = NewTransport(transport)
+
+	return c
+}
+
+// WithRequestSigning signs all requests with the client's secret instead of sending the bearer token.
+func WithRequestSigning() ClientOption {
+	return func(c *Client) error {
+		if c.ds.Credentials == nil || c.ds.Credentials.ClientID == "" || auth.SigningSecret(c.ds.Credentials) == "" {
+			return ErrMissingCredentials
+		}
+		c.signed = true
+		return nil
+	}
+}
+
+// WithoutVersionCheck does not call the VersionCheck, e.g. to ask an API service that does not
+// support the client for its version.
+func WithoutVersionCheck() ClientOption {
+	return func(c *Client) error {
+		c.noVersionCheck = true
+		return nil
+	}
+}
+
+// WithClientCertificate presents the certificate to servers that require mutual TLS.
+func WithClientCertificate(certFile, keyFile string) ClientOption {
+	return func(c *Client) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		if c.tlsConfig == nil {
+			c.tlsConfig = &tls.Config{}
+		}
+		c.tlsConfig.Certificates = []tls.Certificate{cert}
+		return nil
+	}
+}
+
+// WithRootCAs trusts the CAs in caFile instead of the system's CAs, e.g. for private PKIs.
+func WithRootCAs(caFile string) ClientOption {
+	return func(c *Client) error {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return config.ErrInvalidConfiguration
+		}
+		if c.tlsConfig == nil {
+			c.tlsConfig = &tls.Config{}
+		}
+		c.tlsConfig.RootCAs = pool
+		return nil
 	}
 }
 
@@ -102,16 +205,80 @@ func (c *Client) request(method, url string, request, response interface{}) (int
 	return c.roundTrip(req, response)
 }
 
-func (c *Client) roundTrip(req *http.Request, response interface{}) (int, error) {
+// NewRequest creates a request for a path relative to the endpoint or for an absolute URL,
+// e.g. the next page from a Link header. Absolute URLs must have the scheme and host of the
+// endpoint, ErrForeignOrigin otherwise.
+func (c *Client) NewRequest(method, uri string, body io.Reader) (*http.Request, error) {
+	if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+		uri = c.ds.Endpoint + uri
+	}
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	if !c.isEndpoint(req.URL) {
+		return nil, ErrForeignOrigin
+	}
+	return req, nil
+}
+
+// Do sends the request with the client's credentials and returns the response as is, i.e.
+// status codes are not treated as errors. Headers already set on the request are kept.
+// Requests to other origins than the endpoint fail with ErrForeignOrigin.
+// The caller has to close the response body.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if err := c.setHeaders(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkVersion(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
 
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("User-Agent", c.ds.UserAgent)
-	if c.ds.Credentials.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.ds.Credentials.Token)
+// setHeaders adds the default headers and the credentials, unless they are already set.
+// The credentials are only ever sent to the endpoint.
+func (c *Client) setHeaders(req *http.Request) error {
+	if !c.isEndpoint(req.URL) {
+		return ErrForeignOrigin
+	}
+
+	setDefault := func(key, value string) {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+
+	setDefault("Content-Type", "application/json; charset=utf-8")
+	setDefault("User-Agent", c.ds.UserAgent)
+	if c.ds.Credentials.Token != "" && !c.signed {
+		setDefault("Authorization", "Bearer "+c.ds.Credentials.Token)
 	}
 	if c.trace != "" {
-		req.Header.Set("X-Request-ID", c.trace)
-		req.Header.Set("X-Force-Trace", c.trace)
+		setDefault("X-Request-ID", c.trace)
+		setDefault("X-Force-Trace", c.trace)
+	}
+	return nil
+}
+
+// isEndpoint reports if the URL has the scheme and host of the endpoint
+func (c *Client) isEndpoint(u *url.URL) bool {
+	endpoint, err := url.Parse(c.ds.Endpoint)
+	if err != nil || u == nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, endpoint.Scheme) && strings.EqualFold(u.Host, endpoint.Host)
+}
+
+func (c *Client) roundTrip(req *http.Request, response interface{}) (int, error) {
+	if err := c.setHeaders(req); err != nil {
+		return http.StatusBadRequest, err
 	}
 
 	// perform the request
@@ -125,20 +292,15 @@ func (c *Client) roundTrip(req *http.Request, response interface{}) (int, error)
 
 	defer resp.Body.Close()
 
+	if err := c.checkVersion(resp); err != nil {
+		return resp.StatusCode, err
+	}
+
 	// anything other than OK, Created, Accepted, NoContent is treated as an error
 	if resp.StatusCode > http.StatusNoContent {
-		if response != nil {
-			// FIXME make this more generic, not all API calls return a status object!
-
-			// as we expect a response, there might be a StatusObject
-			status := StatusObject{}
-			err = json.NewDecoder(resp.Body).Decode(&status)
-			if err != nil {
-				return resp.StatusCode, fmt.Errorf(MsgStatus, err.Error(), resp.StatusCode)
-			}
-			return status.Status, fmt.Errorf(status.Message)
-		}
-		return resp.StatusCode, ErrApiInvocationError
+		body, _ := io.ReadAll(resp.Body)
+		e := NewError(resp, body)
+		return e.Status, e
 	}
 
 	// unmarshal the response if one is expected
@@ -151,3 +313,35 @@ func (c *Client) roundTrip(req *http.Request, response interface{}) (int, error)
 
 	return resp.StatusCode, nil
 }
+
+// NewError creates the Error of a response. The message is taken from the StatusObject in the
+// body, if there is one, or the status text otherwise.
+func NewError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		Status:    resp.StatusCode,
+		Message:   http.StatusText(resp.StatusCode),
+		RequestID: resp.Header.Get(echo.HeaderXRequestID),
+	}
+
+	// there might be a StatusObject
+	var so StatusObject
+	if err := json.Unmarshal(body, &so); err == nil && so.Message != "" {
+		e.Message = so.Message
+		if so.Status != 0 {
+			e.Status = so.Status
+		}
+	}
+	if e.Message == "" {
+		e.Message = fmt.Sprintf(MsgStatus, ErrApiInvocationError.Error(), e.Status)
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap makes errors.Is(err, ErrApiInvocationError) work
+func (e *Error) Unwrap() error {
+	return ErrApiInvocationError
+}
@@ -2,15 +2,29 @@ package api
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/txsvc/cloudlib/settings"
+
 	"github.com/txsvc/apikit"
+	"github.com/txsvc/apikit/auth"
 )
 
 func TestNewClient(t *testing.T) {
@@ -73,3 +87,184 @@ func testRetryEndpoint(c echo.Context) error {
 
 	return StandardResponse(c, http.StatusServiceUnavailable, nil)
 }
+
+func TestClientRequestSigning(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "signing",
+			ClientID:  "client",
+			Token:     CreateSimpleToken(),
+		},
+		DefaultScopes: []string{auth.ScopeApiRead},
+	}
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	e := echo.New()
+	e.Use(auth.SignatureMiddleware())
+	e.GET("/signed", func(c echo.Context) error {
+		if c.Request().Header.Get("Authorization") != "" {
+			return ErrorResponse(c, http.StatusBadRequest, ErrInvalidRoute, "bearer token")
+		}
+		if _, err := auth.CheckAuthorization(context.TODO(), c, auth.ScopeApiRead); err != nil {
+			return ErrorResponse(c, http.StatusUnauthorized, err, "")
+		}
+		return DefaultEndpoint(c)
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	ds.Endpoint = srv.URL
+
+	cl := NewClient(&ds, WithRequestSigning())
+	assert.NotNil(t, cl)
+
+	status, err := cl.GET("/signed", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// without signing, there is no authorization
+	cl = NewClient(&settings.DialSettings{Endpoint: srv.URL, Credentials: &settings.Credentials{}})
+	status, err = cl.GET("/signed", nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// signing needs credentials
+	assert.Nil(t, NewClient(&settings.DialSettings{Endpoint: srv.URL, Credentials: &settings.Credentials{}}, WithRequestSigning()))
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	// a CA and a client certificate signed by it
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mtls-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, caCert, &clientKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	// register the client and map the certificate
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "mtls",
+			ClientID:  "mtls-client",
+			Token:     CreateSimpleToken(),
+		},
+		DefaultScopes: []string{auth.ScopeApiRead},
+	}
+	assert.NoError(t, auth.UpdateStore(&ds))
+	auth.RegisterClientCertificate("mtls-client", ds.Credentials.Key())
+
+	// a server that requires client certificates
+	e := echo.New()
+	e.Use(auth.ClientCertificateMiddleware())
+	e.GET("/mtls", func(c echo.Context) error {
+		if _, err := auth.CheckAuthorization(context.TODO(), c, auth.ScopeApiRead); err != nil {
+			return ErrorResponse(c, http.StatusUnauthorized, err, "")
+		}
+		return DefaultEndpoint(c)
+	})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	srv := httptest.NewUnstartedServer(e)
+	srv.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(dir, "server-ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+
+	// no token, only the certificate
+	cl := NewClient(&settings.DialSettings{Endpoint: srv.URL, Credentials: &settings.Credentials{}}, WithRootCAs(caFile), WithClientCertificate(certFile, keyFile))
+	assert.NotNil(t, cl)
+
+	status, err := cl.GET("/mtls", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// missing files
+	assert.Nil(t, NewClient(&ds, WithClientCertificate(filepath.Join(dir, "missing.crt"), keyFile)))
+}
+
+func TestNewError(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusNotFound, Header: make(http.Header)}
+	resp.Header.Set(echo.HeaderXRequestID, "req-1")
+
+	e := NewError(resp, []byte(`{"status":404,"message":"no such thing"}`))
+	assert.Equal(t, http.StatusNotFound, e.Status)
+	assert.Equal(t, "no such thing", e.Error())
+	assert.Equal(t, "req-1", e.RequestID)
+	assert.ErrorIs(t, e, ErrApiInvocationError)
+
+	e = NewError(resp, []byte("not json"))
+	assert.Equal(t, http.StatusText(http.StatusNotFound), e.Message)
+}
+
+func TestClientForeignOrigin(t *testing.T) {
+	auths := make([]string, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	ds := settings.DialSettings{
+		Endpoint:    srv.URL,
+		Credentials: &settings.Credentials{ClientID: "client", Token: "secret"},
+	}
+	cl := NewClient(&ds)
+
+	req, err := cl.NewRequest(http.MethodGet, srv.URL+"/absolute", nil)
+	assert.NoError(t, err)
+	resp, err := cl.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// another host or scheme
+	_, err = cl.NewRequest(http.MethodGet, "https://example.com/absolute", nil)
+	assert.ErrorIs(t, err, ErrForeignOrigin)
+	_, err = cl.NewRequest(http.MethodGet, "https"+srv.URL[len("http"):], nil)
+	assert.ErrorIs(t, err, ErrForeignOrigin)
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+
+	req, _ = http.NewRequest(http.MethodGet, other.URL, nil)
+	_, err = cl.Do(req)
+	assert.ErrorIs(t, err, ErrForeignOrigin)
+	_, err = cl.GET("@"+other.Listener.Addr().String()+"/", nil)
+	assert.ErrorIs(t, err, ErrForeignOrigin)
+
+	assert.Equal(t, []string{"Bearer secret"}, auths)
+}
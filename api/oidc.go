@@ -0,0 +1,354 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+// A minimal OpenID Connect relying party: discovery, authorization code flow with PKCE
+// and validation of RS256 signed ID tokens.
+
+const (
+	// DiscoveryPath is appended to the issuer to find the provider's configuration
+	DiscoveryPath = "/.well-known/openid-configuration"
+
+	// OIDCClockSkew is the tolerance when validating exp and iat, in seconds
+	OIDCClockSkew = 60
+)
+
+type (
+	// OIDCConfig configures the relying party
+	OIDCConfig struct {
+		// Issuer is the identity provider's issuer URL, e.g. https://accounts.example.com
+		Issuer string
+		// ClientID and ClientSecret as registered with the identity provider
+		ClientID     string
+		ClientSecret string
+		// RedirectURL points to the OIDCCallbackRoute of this service
+		RedirectURL string
+		// Scopes requested in addition to 'openid', defaults to 'email profile'
+		Scopes []string
+		// ProjectID users are registered with, defaults to the app's name
+		ProjectID string
+		// PostLoginURL is where browsers are sent after a login, if set. Otherwise the token is returned.
+		PostLoginURL string
+	}
+
+	// OIDCProvider is a configured relying party
+	OIDCProvider struct {
+		cfg        OIDCConfig
+		discovery  oidcDiscovery
+		httpClient *http.Client
+
+		keys map[string]*rsa.PublicKey // by kid
+		mu   sync.Mutex                // used to protect the above
+	}
+
+	// IDTokenClaims are the claims of an ID token this package cares about
+	IDTokenClaims struct {
+		Issuer          string   `json:"iss"`
+		Subject         string   `json:"sub"`
+		Audience        audience `json:"aud"`
+		AuthorizedParty string   `json:"azp,omitempty"`
+		Expires         int64    `json:"exp"`
+		IssuedAt        int64    `json:"iat"`
+		Nonce           string   `json:"nonce,omitempty"`
+		Email           string   `json:"email,omitempty"`
+		EmailVerified   bool     `json:"email_verified,omitempty"`
+		Name            string   `json:"name,omitempty"`
+	}
+
+	oidcDiscovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	oidcTokenResponse struct {
+		IDToken     string `json:"id_token"`
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+
+	jsonWebKeySet struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use,omitempty"`
+			Alg string `json:"alg,omitempty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	// audience is either a single string or a list of strings
+	audience []string
+)
+
+var (
+	// ErrInvalidIDToken indicates that the ID token failed validation
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrOIDCDiscovery indicates that the provider's configuration could not be retrieved
+	ErrOIDCDiscovery = errors.New("oidc discovery failed")
+	// ErrOIDCExchange indicates that the authorization code could not be exchanged
+	ErrOIDCExchange = errors.New("oidc code exchange failed")
+)
+
+// NewOIDCProvider discovers the identity provider's configuration and returns a relying party.
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, ErrInvalidRoute
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"email", "profile"}
+	}
+
+	p := &OIDCProvider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+
+	if err := p.getJSON(ctx, strings.TrimSuffix(cfg.Issuer, "/")+DiscoveryPath, &p.discovery); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCDiscovery, err)
+	}
+	if p.discovery.Issuer != cfg.Issuer || p.discovery.AuthorizationEndpoint == "" || p.discovery.TokenEndpoint == "" || p.discovery.JWKSURI == "" {
+		return nil, ErrOIDCDiscovery
+	}
+
+	return p, nil
+}
+
+// AuthCodeURL returns the URL to send the user to in order to authenticate.
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.cfg.ClientID)
+	v.Set("redirect_uri", p.cfg.RedirectURL)
+	v.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", CodeChallenge(verifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + v.Encode()
+}
+
+// Exchange trades the authorization code for tokens and returns the raw ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", p.cfg.RedirectURL)
+	v.Set("client_id", p.cfg.ClientID)
+	v.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret != "" {
+		v.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(MsgStatus, ErrOIDCExchange.Error(), resp.StatusCode)
+	}
+
+	var tr oidcTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.IDToken == "" {
+		return "", ErrOIDCExchange
+	}
+	return tr.IDToken, nil
+}
+
+// VerifyIDToken validates signature, issuer, audience, expiry and nonce of an ID token.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw, nonce string) (*IDTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg '%s'", ErrInvalidIDToken, header.Alg)
+	}
+
+	key, err := p.publicKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	var claims IDTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := stdlib.Now()
+	switch {
+	case claims.Issuer != p.discovery.Issuer:
+		return nil, fmt.Errorf("%w: issuer", ErrInvalidIDToken)
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return nil, fmt.Errorf("%w: audience", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return nil, fmt.Errorf("%w: azp", ErrInvalidIDToken)
+	case claims.Expires < now-OIDCClockSkew:
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case claims.IssuedAt > now+OIDCClockSkew:
+		return nil, fmt.Errorf("%w: iat", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: sub", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+// CodeChallenge returns the PKCE S256 challenge for a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString returns n random bytes, base64url encoded. Used for state, nonce and PKCE verifiers.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// publicKey returns the key with the given kid, refreshing the key set once if it is unknown
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var jwks jsonWebKeySet
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys = keys
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key '%s'", ErrInvalidIDToken, kid)
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(MsgStatus, ErrApiInvocationError.Error(), resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return err
+	}
+	*a = audience(l)
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, aud := range a {
+		if aud == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/auth"
+)
+
+// testIssuer is a local stand-in for an OIDC identity provider
+type testIssuer struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	// authorization requests by code
+	codes map[string]url.Values
+	mu    sync.Mutex
+
+	email string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	iss := &testIssuer{
+		key:   key,
+		codes: make(map[string]url.Values),
+		email: "oidc.user@example.com",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(DiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss.srv.URL,
+			"authorization_endpoint": iss.srv.URL + "/authorize",
+			"token_endpoint":         iss.srv.URL + "/token",
+			"jwks_uri":               iss.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		iss.mu.Lock()
+		authz, ok := iss.codes[r.Form.Get("code")]
+		delete(iss.codes, r.Form.Get("code"))
+		iss.mu.Unlock()
+
+		if !ok || CodeChallenge(r.Form.Get("code_verifier")) != authz.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token":   iss.idToken(t, authz.Get("client_id"), authz.Get("nonce")),
+			"token_type": "Bearer",
+		})
+	})
+	iss.srv = httptest.NewServer(mux)
+
+	return iss
+}
+
+// authorize simulates the user logging in at the identity provider
+func (iss *testIssuer) authorize(location string) (string, string) {
+	u, _ := url.Parse(location)
+
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	code := CreateSimpleToken()
+	iss.codes[code] = u.Query()
+
+	return code, u.Query().Get("state")
+}
+
+func (iss *testIssuer) idToken(t *testing.T, clientID, nonce string) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":            iss.srv.URL,
+		"sub":            "subject-1",
+		"aud":            clientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          iss.email,
+		"email_verified": true,
+	})
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// startLogin starts a login, authenticated with token if set, and returns the redirect and the state cookie
+func startLogin(t *testing.T, e *echo.Echo, token string) (string, *http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, NamespacePrefix+OIDCLoginRoute, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	var state *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == OIDCStateCookieName {
+			state = c
+		}
+	}
+	assert.NotNil(t, state)
+	assert.True(t, state.HttpOnly)
+
+	return rec.Header().Get("Location"), state
+}
+
+// callback returns to the service with a code, as the browser would
+func callback(e *echo.Echo, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, NamespacePrefix+OIDCCallbackRoute+"?code="+code+"&state="+state, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func newTestProvider(t *testing.T, iss *testIssuer) *echo.Echo {
+	p, err := NewOIDCProvider(context.TODO(), OIDCConfig{
+		Issuer:      iss.srv.URL,
+		ClientID:    "apikit",
+		RedirectURL: "http://localhost/a/v1/oidc/callback",
+		ProjectID:   "oidc",
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+
+	return WithOIDCEndpoints(echo.New(), p)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	iss := newTestIssuer(t)
+	defer iss.srv.Close()
+
+	e := newTestProvider(t, iss)
+
+	// start the login
+	location, cookie := startLogin(t, e, "")
+	u, _ := url.Parse(location)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, u.Query().Get("nonce"))
+	assert.NotEqual(t, u.Query().Get("state"), cookie.Value)
+
+	// come back with a code
+	code, state := iss.authorize(location)
+	rec := callback(e, code, state, cookie)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var so StatusObject
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&so))
+	assert.NotEmpty(t, so.Message)
+
+	ds, err := auth.LookupByToken(so.Message)
+	assert.NoError(t, err)
+	assert.Equal(t, "oidc", ds.Credentials.ProjectID)
+	assert.Equal(t, iss.email, ds.Credentials.ClientID)
+	assert.Equal(t, "subject-1", ds.GetOption(OptionOIDCSubject))
+
+	// the state can't be used twice
+	rec = callback(e, code, state, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestOIDCStateCookie(t *testing.T) {
+	iss := newTestIssuer(t)
+	defer iss.srv.Close()
+
+	e := newTestProvider(t, iss)
+
+	// a different browser can't complete the login
+	location, _ := startLogin(t, e, "")
+	code, state := iss.authorize(location)
+	rec := callback(e, code, state, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// neither with the cookie of another login
+	_, other := startLogin(t, e, "")
+	location, _ = startLogin(t, e, "")
+	code, state = iss.authorize(location)
+	rec = callback(e, code, state, other)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestOIDCLinkExistingClient(t *testing.T) {
+	iss := newTestIssuer(t)
+	defer iss.srv.Close()
+	iss.email = "existing.user@example.com"
+
+	e := newTestProvider(t, iss)
+
+	// a client registered via 'auth init'
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "oidc",
+			ClientID:  iss.email,
+			Token:     CreateSimpleToken(),
+			Status:    settings.StateAuthorized,
+		},
+		DefaultScopes: []string{auth.ScopeApiRead},
+	}
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	// is not taken over by a login with the same email
+	location, cookie := startLogin(t, e, "")
+	code, state := iss.authorize(location)
+	rec := callback(e, code, state, cookie)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	_, err := auth.LookupByToken(ds.Credentials.Token)
+	assert.NoError(t, err)
+
+	// unless the client asks for it
+	location, cookie = startLogin(t, e, ds.Credentials.Token)
+	code, state = iss.authorize(location)
+	rec = callback(e, code, state, cookie)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	linked, err := auth.LookupByKey(ds.Credentials.Key())
+	assert.NoError(t, err)
+	assert.Equal(t, "subject-1", linked.GetOption(OptionOIDCSubject))
+	assert.NotEqual(t, ds.Credentials.Token, linked.Credentials.Token)
+
+	// the previous token is no longer valid
+	_, err = auth.LookupByToken(ds.Credentials.Token)
+	assert.ErrorIs(t, err, auth.ErrTokenNotFound)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	iss := newTestIssuer(t)
+	defer iss.srv.Close()
+
+	p, err := NewOIDCProvider(context.TODO(), OIDCConfig{
+		Issuer:      iss.srv.URL,
+		ClientID:    "apikit",
+		RedirectURL: "http://localhost/a/v1/oidc/callback",
+	})
+	assert.NoError(t, err)
+
+	raw := iss.idToken(t, "apikit", "nonce")
+
+	claims, err := p.VerifyIDToken(context.TODO(), raw, "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, "subject-1", claims.Subject)
+
+	// wrong nonce
+	_, err = p.VerifyIDToken(context.TODO(), raw, "other")
+	assert.Error(t, err)
+
+	// wrong audience
+	_, err = p.VerifyIDToken(context.TODO(), iss.idToken(t, "someone-else", "nonce"), "nonce")
+	assert.Error(t, err)
+
+	// tampered payload
+	_, err = p.VerifyIDToken(context.TODO(), raw[:len(raw)-4]+"AAAA", "nonce")
+	assert.Error(t, err)
+}
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 
@@ -19,10 +21,24 @@ const (
 	InitRoute   = "/auth"
 	LoginRoute  = "/auth/:sig/:token"
 	LogoutRoute = "/auth/:sig"
+	MeRoute     = "/auth/me"
+
+	RecoverRoute       = "/auth/recover"
+	RecoverVerifyRoute = "/auth/recover/verify"
 
 	LoginExpiresAfter = 15
 )
 
+type (
+	// RecoveryRequest asks for a recovery challenge and answers it, see auth.RecoveryKey
+	RecoveryRequest struct {
+		ProjectID string `json:"project_id"`
+		ClientID  string `json:"client_id"`
+		Challenge string `json:"challenge,omitempty"`
+		Signature string `json:"signature,omitempty"` // the answer to the challenge
+	}
+)
+
 func WithAuthEndpoints(e *echo.Echo) *echo.Echo {
 	// grouped under /a/v1
 	apiGroup := e.Group(NamespacePrefix)
@@ -31,6 +47,9 @@ func WithAuthEndpoints(e *echo.Echo) *echo.Echo {
 	apiGroup.POST(InitRoute, InitEndpoint)
 	apiGroup.GET(LoginRoute, LoginEndpoint)
 	apiGroup.DELETE(LogoutRoute, LogoutEndpoint)
+	apiGroup.GET(MeRoute, MeEndpoint)
+	apiGroup.POST(RecoverRoute, RecoverEndpoint)
+	apiGroup.POST(RecoverVerifyRoute, RecoverVerifyEndpoint)
 
 	// done
 	return e
@@ -52,11 +71,27 @@ func InitEndpoint(c echo.Context) error {
 	if ds.Credentials == nil || ds.Credentials.ProjectID == "" {
 		return StandardResponse(c, http.StatusBadRequest, nil)
 	}
+	// a client can only register with the tenant the request is addressed to
+	if tenant := auth.Tenant(c); tenant != "" && !strings.EqualFold(tenant, ds.Credentials.ProjectID) {
+		return ErrorResponse(c, http.StatusBadRequest, auth.ErrTenantMismatch, "project")
+	}
+
+	// tenants can have their own set of default scopes
+	scopes := auth.TenantDefaultScopes(ds.Credentials.ProjectID)
+	if len(scopes) == 0 {
+		scopes = config.GetConfig().Settings().GetScopes()
+	}
+
+	// existing clients are never overwritten by an unauthenticated request, see registeredVerifier
+	verifier, status, err := registeredVerifier(ds.Credentials.Key())
+	if err != nil {
+		return ErrorResponse(c, status, err, "client")
+	}
 
 	// create a brand new instance so that the client can't sneak anything in we don't want
 	cfg := settings.DialSettings{
 		Credentials:   ds.Credentials.Clone(),
-		DefaultScopes: config.GetConfig().Settings().GetScopes(),
+		DefaultScopes: scopes,
 	}
 
 	// prepare the settings for registration
@@ -64,12 +99,25 @@ func InitEndpoint(c echo.Context) error {
 	cfg.Credentials.Expires = stdlib.IncT(stdlib.Now(), LoginExpiresAfter)
 	cfg.Credentials.Status = settings.StateInit // signals init
 
+	// the verifier for account recovery, if the client provided one and has none yet
+	if verifier == "" {
+		verifier = ds.GetOption(auth.OptionRecoveryKey)
+		if verifier != "" {
+			if err := auth.ValidRecoveryVerifier(verifier); err != nil {
+				return ErrorResponse(c, http.StatusBadRequest, err, "recovery key")
+			}
+		}
+	}
+	if verifier != "" {
+		cfg.SetOption(auth.OptionRecoveryKey, verifier)
+	}
+
 	if err := auth.UpdateStore(&cfg); err != nil {
 		return StandardResponse(c, http.StatusBadRequest, nil) // FIXME: or 409/Conflict ?
 	}
 
 	// all good so far, send the confirmation
-	err := helpers.MailgunSimpleEmail("ops@txs.vc", cfg.Credentials.ClientID, fmt.Sprintf("your api access credentials (%d)", stdlib.Now()), fmt.Sprintf("the token: %s\n", cfg.Credentials.Token))
+	err = helpers.MailgunSimpleEmail("ops@txs.vc", cfg.Credentials.ClientID, fmt.Sprintf("your api access credentials (%d)", stdlib.Now()), fmt.Sprintf("the token: %s\n", cfg.Credentials.Token))
 	if err != nil {
 		return StandardResponse(c, http.StatusBadRequest, nil)
 	}
@@ -78,6 +126,31 @@ func InitEndpoint(c echo.Context) error {
 	return StandardResponse(c, http.StatusCreated, nil)
 }
 
+// registeredVerifier checks if a client can (re-)initialize and returns the verifier it registered
+// with an earlier login, if any. Authorized and disabled clients can't, neither can clients with a
+// pending init that has not expired. Otherwise anybody knowing the email could revoke the client's
+// token or replace its verifier and take over the account with 'auth recover'.
+func registeredVerifier(key string) (string, int, error) {
+	ds, _ := auth.LookupByKey(key)
+	if ds == nil || ds.Credentials == nil {
+		return "", 0, nil // a new client
+	}
+
+	switch ds.Credentials.Status {
+	case settings.StateAuthorized:
+		return "", http.StatusConflict, auth.ErrAlreadyAuthorized
+	case settings.StateInvalid:
+		return "", http.StatusForbidden, auth.ErrNotAuthorized
+	case settings.StateInit:
+		if ds.Credentials.Expires >= stdlib.Now() {
+			return "", http.StatusConflict, auth.ErrAlreadyInitialized
+		}
+		return "", 0, nil // the email was never confirmed, start over
+	}
+	// logged out, the verifier was confirmed by the login and stays
+	return ds.GetOption(auth.OptionRecoveryKey), 0, nil
+}
+
 func (c *Client) LoginCommand(token string) (*StatusObject, error) {
 	var so StatusObject
 
@@ -99,7 +172,7 @@ func LoginEndpoint(c echo.Context) error {
 	}
 
 	// verify the request
-	ds, err := auth.LookupByToken(token)
+	ds, err := auth.LookupByTenantToken(auth.Tenant(c), token)
 	if ds == nil && err != nil {
 		return ErrorResponse(c, http.StatusBadRequest, ErrInternalError, "token")
 	}
@@ -159,7 +232,7 @@ func LogoutEndpoint(c echo.Context) error {
 	}
 
 	// verify the request
-	cfg, err := auth.LookupByToken(token)
+	cfg, err := auth.LookupByTenantToken(auth.Tenant(c), token)
 	if cfg == nil && err != nil {
 		return ErrorResponse(c, http.StatusBadRequest, ErrInternalError, "token")
 	}
@@ -179,6 +252,146 @@ func LogoutEndpoint(c echo.Context) error {
 	return StandardResponse(c, http.StatusOK, nil)
 }
 
+// RecoverCommand proves the knowledge of the passphrase the recovery key was derived from and
+// returns a new token for the client's credentials, see auth.RecoveryKey.
+func (c *Client) RecoverCommand(key ed25519.PrivateKey) (*StatusObject, error) {
+	req := RecoveryRequest{
+		ProjectID: c.ds.Credentials.ProjectID,
+		ClientID:  c.ds.Credentials.ClientID,
+	}
+	if _, err := c.POST(fmt.Sprintf("%s%s", NamespacePrefix, RecoverRoute), &req, &req); err != nil {
+		return nil, err
+	}
+	req.Signature = auth.SignChallenge(key, req.Challenge)
+
+	var so StatusObject
+	status, err := c.POST(fmt.Sprintf("%s%s", NamespacePrefix, RecoverVerifyRoute), &req, &so)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || so.Message == "" {
+		return nil, ErrApiInvocationError
+	}
+	return &so, nil
+}
+
+// RecoverEndpoint issues a recovery challenge. Unknown clients get one as well, the endpoint
+// does not tell who is registered.
+func RecoverEndpoint(c echo.Context) error {
+	req, err := bindRecoveryRequest(c)
+	if err != nil {
+		return ErrorResponse(c, http.StatusBadRequest, err, "")
+	}
+
+	challenge, err := auth.NewRecoveryChallenge(req.key())
+	if err != nil {
+		return ErrorResponse(c, http.StatusInternalServerError, ErrInternalError, "challenge")
+	}
+
+	return StandardResponse(c, http.StatusOK, RecoveryRequest{
+		ProjectID: req.ProjectID,
+		ClientID:  req.ClientID,
+		Challenge: challenge,
+	})
+}
+
+// RecoverVerifyEndpoint checks the answer to the challenge and replaces the client's token.
+// Only clients that are logged in or logged out can recover. Clients that never logged in can't,
+// otherwise the email would never be verified, and neither can disabled clients.
+func RecoverVerifyEndpoint(c echo.Context) error {
+	req, err := bindRecoveryRequest(c)
+	if err != nil {
+		return ErrorResponse(c, http.StatusBadRequest, err, "")
+	}
+
+	// every failure looks the same, and the challenge is used up in any case
+	verifier := ""
+	ds, _ := auth.LookupByKey(req.key())
+	if ds != nil && (ds.Credentials.Status == settings.StateAuthorized || ds.Credentials.Status == settings.StateUndefined) {
+		verifier = ds.GetOption(auth.OptionRecoveryKey)
+	}
+	if err := auth.VerifyRecovery(verifier, req.key(), req.Challenge, req.Signature); err != nil {
+		return ErrorResponse(c, http.StatusUnauthorized, auth.ErrInvalidCredentials, "recovery")
+	}
+
+	// the passphrase checks out, issue a new token
+	cfg := ds.Clone()
+	cfg.Credentials.Expires = 0
+	cfg.Credentials.Token = CreateSimpleToken()
+	cfg.Credentials.Status = settings.StateAuthorized
+
+	if err := auth.UpdateStore(&cfg); err != nil {
+		return ErrorResponse(c, http.StatusBadRequest, config.ErrInitializingConfiguration, "can't register")
+	}
+
+	return StandardResponse(c, http.StatusOK, StatusObject{
+		Status:  http.StatusOK,
+		Message: cfg.Credentials.Token,
+	})
+}
+
+// bindRecoveryRequest reads and pre-validates a RecoveryRequest
+func bindRecoveryRequest(c echo.Context) (*RecoveryRequest, error) {
+	var req RecoveryRequest
+	if err := c.Bind(&req); err != nil {
+		return nil, err
+	}
+	if req.ProjectID == "" || req.ClientID == "" {
+		return nil, ErrMissingCredentials
+	}
+	// a client can only recover with the tenant the request is addressed to
+	if tenant := auth.Tenant(c); tenant != "" && !strings.EqualFold(tenant, req.ProjectID) {
+		return nil, auth.ErrTenantMismatch
+	}
+	return &req, nil
+}
+
+// key returns the Credentials.Key() of the client
+func (r *RecoveryRequest) key() string {
+	cred := settings.Credentials{ProjectID: r.ProjectID, ClientID: r.ClientID}
+	return cred.Key()
+}
+
+// WhoAmI returns the settings the API has registered for the client's credentials, without secrets.
+func (c *Client) WhoAmI() (*settings.DialSettings, error) {
+	var ds settings.DialSettings
+
+	status, err := c.GET(fmt.Sprintf("%s%s", NamespacePrefix, MeRoute), &ds)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, ErrApiInvocationError
+	}
+	return &ds, nil
+}
+
+// MeEndpoint returns the authorization of the caller, without secrets
+func MeEndpoint(c echo.Context) error {
+	ds, err := auth.CheckAuthorization(c.Request().Context(), c, auth.ScopeApiRead)
+	if err != nil {
+		return ErrorResponse(c, http.StatusUnauthorized, err, "")
+	}
+
+	return StandardResponse(c, http.StatusOK, redactSettings(ds))
+}
+
+// redactSettings returns a copy of the settings without the token, the client secret and secret options
+func redactSettings(ds *settings.DialSettings) *settings.DialSettings {
+	cfg := ds.Clone()
+
+	if cfg.Credentials != nil {
+		cfg.Credentials.Token = ""
+		cfg.Credentials.ClientSecret = ""
+	}
+	for k := range cfg.Options {
+		if config.IsSecretKey(config.KeyOptionPrefix + k) {
+			delete(cfg.Options, k)
+		}
+	}
+	return &cfg
+}
+
 // signature returns a MD5(clientid+token) as this is only known locally ...
 func signature(clientid, token string) string {
 	return stdlib.Fingerprint(fmt.Sprintf("%s%s", clientid, token))
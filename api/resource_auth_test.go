@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+
+	"github.com/txsvc/apikit/auth"
+	"github.com/txsvc/apikit/config"
+)
+
+func TestWhoAmI(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID:    "whoami",
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Token:        CreateSimpleToken(),
+			Status:       settings.StateAuthorized,
+		},
+		DefaultScopes: []string{auth.ScopeApiRead},
+	}
+	ds.SetOption(config.OptionAPIKey, "apikey")
+	ds.SetOption("region", "eu")
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	srv := httptest.NewServer(WithAuthEndpoints(echo.New()))
+	defer srv.Close()
+
+	ds.Endpoint = srv.URL
+
+	me, err := NewClient(&ds).WhoAmI()
+	assert.NoError(t, err)
+	if assert.NotNil(t, me) {
+		assert.Equal(t, "client", me.Credentials.ClientID)
+		assert.Equal(t, "whoami", me.Credentials.ProjectID)
+		assert.Equal(t, settings.StateAuthorized, me.Credentials.Status)
+		assert.Equal(t, []string{auth.ScopeApiRead}, me.GetScopes())
+		assert.Empty(t, me.Credentials.Token)
+		assert.Empty(t, me.Credentials.ClientSecret)
+		assert.False(t, me.HasOption(config.OptionAPIKey))
+		assert.Equal(t, "eu", me.GetOption("region"))
+	}
+
+	// unknown token
+	unknown := ds.Clone()
+	unknown.Credentials.Token = CreateSimpleToken()
+	me, err = NewClient(&unknown).WhoAmI()
+	assert.Error(t, err)
+	assert.Nil(t, me)
+
+	// no token at all
+	rec := httptest.NewRecorder()
+	WithAuthEndpoints(echo.New()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, NamespacePrefix+MeRoute, nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRecover(t *testing.T) {
+	phrase := "hard trend birth pioneer hero immune apology cook foam hurt tattoo artist"
+	key, err := auth.RecoveryKey("recover", "me@example.com", phrase)
+	assert.NoError(t, err)
+
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "recover",
+			ClientID:  "me@example.com",
+			Token:     CreateSimpleToken(),
+			Status:    settings.StateAuthorized,
+		},
+		DefaultScopes: []string{auth.ScopeApiRead},
+	}
+	ds.SetOption(auth.OptionRecoveryKey, auth.RecoveryVerifier(key))
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	srv := httptest.NewServer(WithAuthEndpoints(echo.New()))
+	defer srv.Close()
+
+	// a new machine only knows the email and the passphrase
+	cfg := settings.DialSettings{
+		Endpoint:    srv.URL,
+		Credentials: &settings.Credentials{ProjectID: "recover", ClientID: "me@example.com"},
+	}
+	so, err := NewClient(&cfg).RecoverCommand(key)
+	assert.NoError(t, err)
+	if assert.NotNil(t, so) {
+		assert.NotEqual(t, ds.Credentials.Token, so.Message)
+
+		recovered, err := auth.LookupByToken(so.Message)
+		assert.NoError(t, err)
+		assert.Equal(t, settings.StateAuthorized, recovered.Credentials.Status)
+		assert.Equal(t, []string{auth.ScopeApiRead}, recovered.GetScopes())
+	}
+	_, err = auth.LookupByToken(ds.Credentials.Token)
+	assert.Error(t, err)
+
+	// the wrong passphrase
+	wrong, _ := auth.RecoveryKey("recover", "me@example.com", "another passphrase")
+	_, err = NewClient(&cfg).RecoverCommand(wrong)
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	}
+
+	// unknown clients get a challenge, but can't answer it
+	unknown := cfg.Clone()
+	unknown.Credentials.ClientID = "you@example.com"
+	_, err = NewClient(&unknown).RecoverCommand(key)
+	assert.Error(t, err)
+
+	// clients that never logged in can't recover
+	ds.Credentials.ClientID = "new@example.com"
+	ds.Credentials.Status = settings.StateInit
+	newKey, _ := auth.RecoveryKey("recover", "new@example.com", phrase)
+	ds.SetOption(auth.OptionRecoveryKey, auth.RecoveryVerifier(newKey))
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	pending := cfg.Clone()
+	pending.Credentials.ClientID = "new@example.com"
+	_, err = NewClient(&pending).RecoverCommand(newKey)
+	assert.Error(t, err)
+
+	// disabled clients can't recover
+	ds.Credentials.ClientID = "disabled@example.com"
+	ds.Credentials.Status = settings.StateAuthorized
+	disabledKey, _ := auth.RecoveryKey("recover", "disabled@example.com", phrase)
+	ds.SetOption(auth.OptionRecoveryKey, auth.RecoveryVerifier(disabledKey))
+	assert.NoError(t, auth.UpdateStore(&ds))
+	stored, _ := auth.LookupByKey(ds.Credentials.Key())
+	stored.Credentials.Status = settings.StateInvalid // the store only accepts valid credentials
+
+	disabled := cfg.Clone()
+	disabled.Credentials.ClientID = "disabled@example.com"
+	_, err = NewClient(&disabled).RecoverCommand(disabledKey)
+	assert.Error(t, err)
+	assert.Equal(t, settings.StateInvalid, stored.Credentials.Status)
+
+	// missing fields
+	rec := httptest.NewRecorder()
+	WithAuthEndpoints(echo.New()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, NamespacePrefix+RecoverRoute, nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRecoverCommandStatus(t *testing.T) {
+	key, _ := auth.RecoveryKey("recover", "me@example.com", "a passphrase")
+
+	// any success other than OK has no token
+	e := echo.New()
+	e.POST(NamespacePrefix+RecoverRoute, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, RecoveryRequest{Challenge: "challenge"})
+	})
+	e.POST(NamespacePrefix+RecoverVerifyRoute, func(c echo.Context) error {
+		return c.JSON(http.StatusCreated, NewStatus(http.StatusCreated, "created"))
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	cfg := settings.DialSettings{
+		Endpoint:    srv.URL,
+		Credentials: &settings.Credentials{ProjectID: "recover", ClientID: "me@example.com"},
+	}
+	so, err := NewClient(&cfg).RecoverCommand(key)
+	assert.ErrorIs(t, err, ErrApiInvocationError)
+	assert.Nil(t, so)
+}
+
+func TestInitExistingClient(t *testing.T) {
+	e := WithAuthEndpoints(echo.New())
+	initClient := func(clientID, verifier string) int {
+		ds := settings.DialSettings{
+			Credentials: &settings.Credentials{ProjectID: "existing", ClientID: clientID},
+		}
+		ds.SetOption(auth.OptionRecoveryKey, verifier)
+		body, _ := json.Marshal(&ds)
+
+		req := httptest.NewRequest(http.MethodPost, NamespacePrefix+InitRoute, bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	owner, _ := auth.RecoveryKey("existing", "me@example.com", "the owner's passphrase")
+	attacker, _ := auth.RecoveryKey("existing", "me@example.com", "somebody else's passphrase")
+
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "existing",
+			ClientID:  "me@example.com",
+			Token:     CreateSimpleToken(),
+			Status:    settings.StateAuthorized,
+		},
+	}
+	ds.SetOption(auth.OptionRecoveryKey, auth.RecoveryVerifier(owner))
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	// an authorized client keeps its token and its verifier
+	assert.Equal(t, http.StatusConflict, initClient("me@example.com", auth.RecoveryVerifier(attacker)))
+	stored, err := auth.LookupByToken(ds.Credentials.Token)
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, settings.StateAuthorized, stored.Credentials.Status)
+		assert.Equal(t, auth.RecoveryVerifier(owner), stored.GetOption(auth.OptionRecoveryKey))
+	}
+
+	// a logged out client can init again, but keeps its verifier
+	ds.Credentials.Status = settings.StateUndefined
+	assert.NoError(t, auth.UpdateStore(&ds))
+	initClient("me@example.com", auth.RecoveryVerifier(attacker)) // sending the email fails in tests
+	stored, _ = auth.LookupByKey(ds.Credentials.Key())
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, settings.StateInit, stored.Credentials.Status)
+		assert.Equal(t, auth.RecoveryVerifier(owner), stored.GetOption(auth.OptionRecoveryKey))
+	}
+
+	// a pending init can't be replaced until it expired
+	assert.Equal(t, http.StatusConflict, initClient("me@example.com", auth.RecoveryVerifier(attacker)))
+	stored.Credentials.Expires = stdlib.Now() - 1
+	assert.NotEqual(t, http.StatusConflict, initClient("me@example.com", auth.RecoveryVerifier(attacker)))
+}
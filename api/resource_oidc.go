@@ -0,0 +1,255 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+
+	"github.com/txsvc/apikit/auth"
+	"github.com/txsvc/apikit/config"
+)
+
+const (
+	// oidc routes
+	OIDCLoginRoute    = "/oidc/login"
+	OIDCCallbackRoute = "/oidc/callback"
+
+	// OIDCLoginExpiresAfter is the time a user has to complete the login, in minutes
+	OIDCLoginExpiresAfter = 10
+	// OIDCStateCookieName is the name of the cookie that binds a login to the browser that started it
+	OIDCStateCookieName = "apikit_oidc_state"
+
+	// options stored with clients created or linked by an OIDC login
+	OptionOIDCIssuer  = "oidc_issuer"
+	OptionOIDCSubject = "oidc_subject"
+)
+
+type (
+	// oidcLogin is the state of a login in progress
+	oidcLogin struct {
+		nonce    string
+		verifier string
+		tenant   string
+		link     string // the key of the client that asked to be linked, if any
+		expires  int64
+	}
+)
+
+var (
+	// ErrInvalidState indicates that the login state is unknown or expired
+	ErrInvalidState = errors.New("invalid state")
+	// ErrIdentityConflict indicates that the client is already linked to a different identity
+	ErrIdentityConflict = errors.New("identity conflict")
+	// ErrNotLinked indicates that a client exists but was never linked to an identity
+	ErrNotLinked = errors.New("client is not linked")
+
+	// logins in progress, by state
+	oidcLogins = make(map[string]*oidcLogin)
+	omu        sync.Mutex // used to protect the above
+)
+
+func init() {
+	// make the options known to config.Validate()
+	config.RegisterOptions(OptionOIDCIssuer, OptionOIDCSubject)
+}
+
+// WithOIDCEndpoints adds the login and callback routes of the OIDC relying party.
+func WithOIDCEndpoints(e *echo.Echo, p *OIDCProvider) *echo.Echo {
+	// grouped under /a/v1
+	apiGroup := e.Group(NamespacePrefix)
+
+	// add the routes
+	apiGroup.GET(OIDCLoginRoute, p.LoginEndpoint)
+	apiGroup.GET(OIDCCallbackRoute, p.CallbackEndpoint)
+
+	// done
+	return e
+}
+
+// LoginEndpoint starts the authorization code flow by redirecting to the identity provider.
+// An existing client is linked to the identity only if it is authenticated when starting the login.
+func (p *OIDCProvider) LoginEndpoint(c echo.Context) error {
+	state, err := RandomString(24)
+	if err != nil {
+		return ErrorResponse(c, http.StatusInternalServerError, err, "state")
+	}
+	nonce, err := RandomString(24)
+	if err != nil {
+		return ErrorResponse(c, http.StatusInternalServerError, err, "nonce")
+	}
+	verifier, err := RandomString(32)
+	if err != nil {
+		return ErrorResponse(c, http.StatusInternalServerError, err, "verifier")
+	}
+
+	link := ""
+	if ds, err := auth.CheckAuthorization(c.Request().Context(), c, auth.ScopeApiRead); err == nil && ds.Credentials.Status == settings.StateAuthorized {
+		link = ds.Credentials.Key()
+	}
+
+	omu.Lock()
+	now := stdlib.Now()
+	for s, l := range oidcLogins {
+		if l.expires < now {
+			delete(oidcLogins, s)
+		}
+	}
+	oidcLogins[state] = &oidcLogin{
+		nonce:    nonce,
+		verifier: verifier,
+		tenant:   auth.Tenant(c),
+		link:     link,
+		expires:  stdlib.IncT(now, OIDCLoginExpiresAfter),
+	}
+	omu.Unlock()
+
+	c.SetCookie(stateCookie(stateHash(state), OIDCLoginExpiresAfter*60, c.Scheme() == "https"))
+
+	return c.Redirect(http.StatusFound, p.AuthCodeURL(state, nonce, verifier))
+}
+
+// CallbackEndpoint completes the flow, creates or links the client and issues an apikit token.
+func (p *OIDCProvider) CallbackEndpoint(c echo.Context) error {
+	if e := c.QueryParam("error"); e != "" {
+		return ErrorResponse(c, http.StatusUnauthorized, auth.ErrNotAuthorized, e)
+	}
+
+	state := c.QueryParam("state")
+	code := c.QueryParam("code")
+	if state == "" || code == "" {
+		return ErrorResponse(c, http.StatusBadRequest, ErrInvalidRoute, "state, code")
+	}
+
+	// a state can only be used once
+	omu.Lock()
+	login, ok := oidcLogins[state]
+	delete(oidcLogins, state)
+	omu.Unlock()
+
+	// ... and only by the browser that started the login
+	cookie, err := c.Cookie(OIDCStateCookieName)
+	c.SetCookie(stateCookie("", -1, c.Scheme() == "https"))
+
+	if !ok || login.expires < stdlib.Now() {
+		return ErrorResponse(c, http.StatusBadRequest, ErrInvalidState, "")
+	}
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(stateHash(state))) != 1 {
+		return ErrorResponse(c, http.StatusBadRequest, ErrInvalidState, "cookie")
+	}
+
+	ctx := c.Request().Context()
+	raw, err := p.Exchange(ctx, code, login.verifier)
+	if err != nil {
+		return ErrorResponse(c, http.StatusUnauthorized, err, "")
+	}
+	claims, err := p.VerifyIDToken(ctx, raw, login.nonce)
+	if err != nil {
+		return ErrorResponse(c, http.StatusUnauthorized, err, "")
+	}
+
+	cfg, err := p.linkClient(claims, login)
+	if err != nil {
+		return ErrorResponse(c, http.StatusConflict, err, "")
+	}
+	// the new token replaces the previous one of a linked client
+	if err := auth.UpdateStore(cfg); err != nil {
+		return ErrorResponse(c, http.StatusBadRequest, config.ErrInitializingConfiguration, "can't register")
+	}
+
+	// browsers get a session, if sessions are enabled
+	secure := c.Scheme() == "https"
+	if cookie, err := auth.NewSessionCookie(cfg.Credentials.Token, secure); err == nil {
+		c.SetCookie(cookie)
+		c.SetCookie(auth.NewCSRFCookie(cfg.Credentials.Token, secure))
+
+		if p.cfg.PostLoginURL != "" {
+			return c.Redirect(http.StatusFound, p.cfg.PostLoginURL)
+		}
+	}
+
+	// just send the token back
+	resp := StatusObject{
+		Status:  http.StatusOK,
+		Message: cfg.Credentials.Token,
+	}
+	return StandardResponse(c, http.StatusOK, resp)
+}
+
+// linkClient creates a new client for the identity or updates the one that is already linked to it.
+// A client that is not linked yet, e.g. one registered via 'auth init', is only linked if it started the login.
+func (p *OIDCProvider) linkClient(claims *IDTokenClaims, login *oidcLogin) (*settings.DialSettings, error) {
+	projectID := p.cfg.ProjectID
+	if login.tenant != "" {
+		projectID = login.tenant
+	}
+	if projectID == "" {
+		projectID = config.GetConfig().Info().Name()
+	}
+
+	// prefer a verified email as the client id, it matches clients registered via 'auth init'
+	clientID := claims.Subject
+	if claims.Email != "" && claims.EmailVerified {
+		clientID = strings.ToLower(claims.Email)
+	}
+
+	cred := settings.Credentials{ProjectID: projectID, ClientID: clientID}
+
+	var cfg settings.DialSettings
+	if ds, err := auth.LookupByKey(cred.Key()); err == nil && ds != nil {
+		// link with the existing client, unless it belongs to someone else
+		sub := ds.GetOption(OptionOIDCSubject)
+		if sub != "" && (sub != claims.Subject || ds.GetOption(OptionOIDCIssuer) != claims.Issuer) {
+			return nil, ErrIdentityConflict
+		}
+		if sub == "" && login.link != cred.Key() {
+			return nil, ErrNotLinked
+		}
+		cfg = ds.Clone()
+	} else {
+		scopes := auth.TenantDefaultScopes(projectID)
+		if len(scopes) == 0 {
+			scopes = config.GetConfig().Settings().GetScopes()
+		}
+		cfg = settings.DialSettings{
+			Credentials:   cred.Clone(),
+			DefaultScopes: scopes,
+		}
+	}
+
+	cfg.SetOption(OptionOIDCIssuer, claims.Issuer)
+	cfg.SetOption(OptionOIDCSubject, claims.Subject)
+	cfg.Credentials.Token = CreateSimpleToken()
+	cfg.Credentials.Expires = 0
+	cfg.Credentials.Status = settings.StateAuthorized
+
+	return &cfg, nil
+}
+
+// stateCookie binds a login to the browser. It has to be sent on the redirect back from the
+// identity provider, hence SameSite=Lax.
+func stateCookie(value string, maxAge int, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    value,
+		Path:     NamespacePrefix + OIDCCallbackRoute,
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// stateHash is stored in the state cookie instead of the state itself
+func stateHash(state string) string {
+	h := sha256.Sum256([]byte("oidc_state:" + state))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/auth"
+	"github.com/txsvc/apikit/config"
+)
+
+const (
+	// session routes
+	SessionRoute = "/session"
+)
+
+type (
+	// SessionResponse is returned after a successful session login
+	SessionResponse struct {
+		Status    int    `json:"status"`
+		CSRFToken string `json:"csrf_token"`
+	}
+)
+
+// WithSessionEndpoints adds cookie based browser sessions, keyed by config.AppSessionKeys(),
+// and the CSRF protection needed for cookie-authenticated requests.
+func WithSessionEndpoints(e *echo.Echo) *echo.Echo {
+	auth.SetSessionKeys(config.AppSessionKeys()...)
+
+	// protect all cookie-authenticated, unsafe requests
+	e.Use(auth.CSRFMiddleware())
+
+	// grouped under /a/v1
+	apiGroup := e.Group(NamespacePrefix)
+
+	// add the routes
+	apiGroup.POST(SessionRoute, SessionLoginEndpoint)
+	apiGroup.DELETE(SessionRoute, SessionLogoutEndpoint)
+
+	// done
+	return e
+}
+
+// SessionLoginEndpoint exchanges a valid bearer token for a session cookie
+func SessionLoginEndpoint(c echo.Context) error {
+	token, err := auth.GetBearerToken(c.Request())
+	if err != nil {
+		return ErrorResponse(c, http.StatusUnauthorized, err, "")
+	}
+
+	ds, err := auth.LookupByTenantToken(auth.Tenant(c), token)
+	if err != nil || ds == nil || !ds.Credentials.IsValid() || ds.Credentials.Status != settings.StateAuthorized {
+		return ErrorResponse(c, http.StatusUnauthorized, auth.ErrNotAuthorized, "")
+	}
+
+	secure := c.Scheme() == "https"
+	cookie, err := auth.NewSessionCookie(token, secure)
+	if err != nil {
+		return ErrorResponse(c, http.StatusInternalServerError, err, "session")
+	}
+	c.SetCookie(cookie)
+	c.SetCookie(auth.NewCSRFCookie(token, secure))
+
+	resp := SessionResponse{
+		Status:    http.StatusOK,
+		CSRFToken: auth.CSRFToken(token),
+	}
+	return StandardResponse(c, http.StatusOK, resp)
+}
+
+// SessionLogoutEndpoint clears the session cookie and revokes the token it carries, i.e. the
+// token can't be used as a bearer token either.
+func SessionLogoutEndpoint(c echo.Context) error {
+	if token, err := auth.GetSessionToken(c.Request()); err == nil {
+		if err := auth.RevokeToken(token); err != nil && err != auth.ErrTokenNotFound {
+			return ErrorResponse(c, http.StatusInternalServerError, err, "session")
+		}
+	}
+
+	for _, cookie := range auth.ClearSessionCookies(c.Scheme() == "https") {
+		c.SetCookie(cookie)
+	}
+	return StandardResponse(c, http.StatusOK, nil)
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/txsvc/stdlib/v2"
+
+	"github.com/txsvc/apikit/config"
+)
+
+const (
+	// version routes
+	VersionRoute = "/version"
+
+	// MinClientVersionENV sets the oldest client version the service supports, e.g. '1.2.0'
+	MinClientVersionENV = "APIKIT_MIN_CLIENT_VERSION"
+
+	// headers added to every response by VersionMiddleware
+	HeaderAPIVersion       = "X-Api-Version"
+	HeaderMinClientVersion = "X-Api-Min-Client-Version"
+)
+
+type (
+	// VersionResponse describes the build of the service
+	VersionResponse struct {
+		Name             string `json:"name"`
+		Version          string `json:"version"`
+		Major            int    `json:"major"`
+		Minor            int    `json:"minor"`
+		Fix              int    `json:"fix"`
+		Commit           string `json:"commit,omitempty"`
+		CommitTime       string `json:"commit_time,omitempty"`
+		BuildTime        string `json:"build_time,omitempty"`
+		Dirty            bool   `json:"dirty,omitempty"`
+		MinClientVersion string `json:"min_client_version,omitempty"`
+	}
+
+	// VersionCheck is called by clients with the version headers of every response, "" if a header
+	// is missing. An error fails the request, see SetVersionCheck.
+	VersionCheck func(serverVersion, minClientVersion string) error
+)
+
+var (
+	// the oldest supported client version, "" if there is none
+	minClientVersion atomic.Value
+	// the check of all clients, if any
+	versionCheck atomic.Pointer[VersionCheck]
+)
+
+func init() {
+	minClientVersion.Store("")
+	_ = SetMinClientVersion(stdlib.GetString(MinClientVersionENV, "")) // invalid values are ignored
+}
+
+// SetMinClientVersion sets the oldest client version the service supports, "" supports all.
+// It is reported by the version endpoint and VersionMiddleware.
+func SetMinClientVersion(v string) error {
+	if v != "" {
+		if _, err := config.CompareVersions(v, v); err != nil {
+			return err
+		}
+	}
+	minClientVersion.Store(v)
+	return nil
+}
+
+// MinClientVersion returns the oldest client version the service supports
+func MinClientVersion() string {
+	v, _ := minClientVersion.Load().(string)
+	return v
+}
+
+// WithVersionEndpoint adds the unauthenticated version route and VersionMiddleware
+func WithVersionEndpoint(e *echo.Echo) *echo.Echo {
+	// version headers on all responses
+	e.Use(VersionMiddleware())
+
+	// grouped under /a/v1
+	apiGroup := e.Group(NamespacePrefix)
+
+	// add the routes
+	apiGroup.GET(VersionRoute, VersionEndpoint)
+
+	// done
+	return e
+}
+
+// VersionMiddleware adds the version of the service and the oldest supported client version to
+// the headers of every response, so that clients can check them with each request.
+func VersionMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := c.Response().Header()
+			h.Set(HeaderAPIVersion, config.GetConfig().Info().VersionString())
+			if v := MinClientVersion(); v != "" {
+				h.Set(HeaderMinClientVersion, v)
+			}
+			return next(c)
+		}
+	}
+}
+
+// SetVersionCheck sets the check clients run on every response, nil removes it. Clients created
+// with WithoutVersionCheck skip it.
+func SetVersionCheck(check VersionCheck) {
+	if check == nil {
+		versionCheck.Store(nil)
+		return
+	}
+	versionCheck.Store(&check)
+}
+
+// ServerVersion returns the version of the API service and the oldest client version it supports,
+// as reported with the last response
+func (c *Client) ServerVersion() (string, string) {
+	c.vmu.Lock()
+	defer c.vmu.Unlock()
+
+	return c.serverVersion, c.minClientVersion
+}
+
+// checkVersion records the version headers of a response and runs the VersionCheck
+func (c *Client) checkVersion(resp *http.Response) error {
+	serverVersion := resp.Header.Get(HeaderAPIVersion)
+	minClientVersion := resp.Header.Get(HeaderMinClientVersion)
+
+	c.vmu.Lock()
+	c.serverVersion, c.minClientVersion = serverVersion, minClientVersion
+	c.vmu.Unlock()
+
+	check := versionCheck.Load()
+	if check == nil || c.noVersionCheck {
+		return nil
+	}
+	return (*check)(serverVersion, minClientVersion)
+}
+
+// Version returns the version of the API service
+func (c *Client) Version() (*VersionResponse, error) {
+	var resp VersionResponse
+
+	status, err := c.GET(fmt.Sprintf("%s%s", NamespacePrefix, VersionRoute), &resp)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, ErrApiInvocationError
+	}
+	return &resp, nil
+}
+
+// VersionEndpoint reports the version and build metadata of the service
+func VersionEndpoint(c echo.Context) error {
+	return StandardResponse(c, http.StatusOK, NewVersionResponse(config.GetConfig().Info()))
+}
+
+// NewVersionResponse creates a VersionResponse from the app info
+func NewVersionResponse(info *config.Info) *VersionResponse {
+	resp := &VersionResponse{
+		Name:             info.Name(),
+		Version:          info.VersionString(),
+		Major:            info.MajorVersion(),
+		Minor:            info.MinorVersion(),
+		Fix:              info.FixVersion(),
+		Commit:           info.Commit(),
+		Dirty:            info.Dirty(),
+		MinClientVersion: MinClientVersion(),
+	}
+	if !info.CommitTime().IsZero() {
+		resp.CommitTime = info.CommitTime().UTC().Format(time.RFC3339)
+	}
+	if !info.BuildTime().IsZero() {
+		resp.BuildTime = info.BuildTime().UTC().Format(time.RFC3339)
+	}
+	return resp
+}
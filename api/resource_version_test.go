@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/config"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	e := WithVersionEndpoint(echo.New())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, NamespacePrefix+VersionRoute, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp VersionResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	info := config.GetConfig().Info()
+	assert.Equal(t, info.Name(), resp.Name)
+	assert.Equal(t, info.VersionString(), resp.Version)
+	assert.Equal(t, info.MinorVersion(), resp.Minor)
+}
+
+func TestVersionHeaders(t *testing.T) {
+	assert.NoError(t, SetMinClientVersion("1.2.0"))
+	defer SetMinClientVersion("")
+	assert.ErrorIs(t, SetMinClientVersion("latest"), config.ErrInvalidVersion)
+	assert.Equal(t, "1.2.0", MinClientVersion())
+
+	srv := httptest.NewServer(WithVersionEndpoint(echo.New()))
+	defer srv.Close()
+
+	cl := NewClient(&settings.DialSettings{Endpoint: srv.URL, Credentials: &settings.Credentials{}})
+	v, err := cl.Version()
+	assert.NoError(t, err)
+	if assert.NotNil(t, v) {
+		assert.Equal(t, "1.2.0", v.MinClientVersion)
+	}
+
+	server, minClient := cl.ServerVersion()
+	assert.Equal(t, config.GetConfig().Info().VersionString(), server)
+	assert.Equal(t, "1.2.0", minClient)
+
+	// the check fails every request, unless the client skips it
+	SetVersionCheck(func(server, minClient string) error {
+		return ErrApiInvocationError
+	})
+	defer SetVersionCheck(nil)
+
+	_, err = cl.Version()
+	assert.ErrorIs(t, err, ErrApiInvocationError)
+
+	req, err := cl.NewRequest(http.MethodGet, NamespacePrefix+VersionRoute, nil)
+	assert.NoError(t, err)
+	_, err = cl.Do(req)
+	assert.ErrorIs(t, err, ErrApiInvocationError)
+
+	_, err = NewClient(&settings.DialSettings{Endpoint: srv.URL, Credentials: &settings.Credentials{}}, WithoutVersionCheck()).Version()
+	assert.NoError(t, err)
+
+	// a client can be shared
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = cl.Version()
+			_, _ = cl.ServerVersion()
+		}()
+	}
+	wg.Wait()
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/PuerkitoBio/rehttp"
 	"github.com/txsvc/cloudlib/observer"
+
+	"github.com/txsvc/apikit/auth"
 )
 
 type (
@@ -17,6 +19,12 @@ type (
 		InnerTransport http.RoundTripper
 	}
 
+	signingTransport struct {
+		InnerTransport http.RoundTripper
+		keyID          string
+		secret         string
+	}
+
 	contextKey struct {
 		name string
 	}
@@ -44,6 +52,25 @@ func NewTransport(transport http.RoundTripper) *http.Client {
 	}
 }
 
+// NewSigningTransport signs each request with the shared secret before passing it on.
+// Use it as the inner transport of NewTransport so that retries are signed again.
+func NewSigningTransport(transport http.RoundTripper, keyID, secret string) http.RoundTripper {
+	return &signingTransport{
+		InnerTransport: transport,
+		keyID:          keyID,
+		secret:         secret,
+	}
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the original request
+	req = req.Clone(req.Context())
+	if err := auth.SignRequest(req, t.keyID, t.secret); err != nil {
+		return nil, err
+	}
+	return t.InnerTransport.RoundTrip(req)
+}
+
 func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	ctx := context.WithValue(req.Context(), contextKeyRequestStart, time.Now())
 	req = req.WithContext(ctx)
@@ -3,6 +3,8 @@ package apikit
 import (
 	"context"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -10,6 +12,8 @@ import (
 	"github.com/labstack/gommon/log"
 	"github.com/ziflex/lecho/v3"
 
+	"github.com/txsvc/cloudlib/settings"
+
 	"github.com/txsvc/apikit/config"
 )
 
@@ -34,14 +38,19 @@ type (
 
 		shutdown      ShutdownFunc
 		shutdownDelay time.Duration
+		unsubscribe   func() // stops the updates from config.OnChange
 
 		// other settings
 		logLevel log.Lvl
 		root     string
+
+		mu sync.Mutex // used to protect shutdownDelay and logLevel, they change on reload
 	}
 )
 
 // New creates a new service listener instance and configures it with sensible defaults.
+// Problems with the settings are logged, see config.Validate(). Only config.Reload() rejects
+// invalid settings.
 func New(setupFunc SetupFunc, shutdownFunc ShutdownFunc) (*App, error) {
 	if setupFunc == nil || shutdownFunc == nil {
 		return nil, config.ErrInvalidConfiguration
@@ -50,8 +59,8 @@ func New(setupFunc SetupFunc, shutdownFunc ShutdownFunc) (*App, error) {
 	app := &App{
 		svc:           setupFunc(),
 		shutdown:      shutdownFunc,
-		logLevel:      log.INFO,
-		shutdownDelay: ShutdownDelay * time.Second,
+		logLevel:      parseLogLevel(config.Server().LogLevel, log.INFO),
+		shutdownDelay: config.Server().ShutdownDelay,
 	}
 
 	if app.svc == nil {
@@ -75,6 +84,10 @@ func New(setupFunc SetupFunc, shutdownFunc ShutdownFunc) (*App, error) {
 	// FIXME: add a default error handler
 	// app.mux.HTTPErrorHandler = ...
 
+	if err := config.Validate(); err != nil {
+		app.svc.Logger.Warnf("invalid configuration: %v", err)
+	}
+
 	// the root dir for the config
 	dir, err := os.Getwd()
 	if err != nil {
@@ -82,12 +95,57 @@ func New(setupFunc SetupFunc, shutdownFunc ShutdownFunc) (*App, error) {
 	}
 	app.root = dir
 
+	// pick up changes of the log level and shutdown delay without a restart
+	app.unsubscribe = config.OnChange(func(_, _ *settings.DialSettings) {
+		app.reconfigure()
+	})
+
 	return app, nil
 }
 
+// reconfigure applies the server settings that can change while the app is running
+func (a *App) reconfigure() {
+	srv := config.Server()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.logLevel = parseLogLevel(srv.LogLevel, a.logLevel)
+	a.svc.Logger.SetLevel(a.logLevel)
+	a.shutdownDelay = srv.ShutdownDelay
+}
+
+// parseLogLevel accepts 'debug', 'info', 'warn', 'error' and 'off'
+func parseLogLevel(level string, def log.Lvl) log.Lvl {
+	switch strings.ToLower(level) {
+	case "debug":
+		return log.DEBUG
+	case "info":
+		return log.INFO
+	case "warn":
+		return log.WARN
+	case "error":
+		return log.ERROR
+	case "off":
+		return log.OFF
+	}
+	return def
+}
+
+// shutdownTimeout returns the current shutdown delay
+func (a *App) shutdownTimeout() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.shutdownDelay
+}
+
 func (a *App) Stop() {
+	// no more reloads for this app
+	a.unsubscribe()
+
 	// FIXME: this does not work !
-	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout())
 	defer cancel()
 
 	// FIXME: which one comes first ? framwork or app shutdown ?
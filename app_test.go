@@ -3,11 +3,15 @@ package apikit
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/apikit/config"
 )
 
 func nilSetup() *echo.Echo {
@@ -91,3 +95,62 @@ func _TestRunStopTimeout(t *testing.T) {
 	time.Sleep(10 * time.Second)
 }
 */
+
+func TestReconfigure(t *testing.T) {
+	svc, err := New(simpleSetup, noopShutdown)
+	assert.NoError(t, err)
+	assert.Equal(t, log.INFO, svc.logLevel)
+
+	config.SetFlag(config.KeyLogLevel, "debug")
+	defer config.ResetFlags()
+
+	assert.NoError(t, config.Reload())
+	assert.Equal(t, log.DEBUG, svc.logLevel)
+	assert.Equal(t, log.DEBUG, svc.svc.Logger.Level())
+}
+
+func TestNewInvalidSettings(t *testing.T) {
+	config.SetFlag(config.KeyEndpoint, "not a url")
+	defer config.ResetFlags()
+
+	// only logged, the service still starts
+	svc, err := New(simpleSetup, noopShutdown)
+	assert.NotNil(t, svc)
+	assert.NoError(t, err)
+	svc.unsubscribe()
+}
+
+func TestUnsubscribe(t *testing.T) {
+	svc, err := New(simpleSetup, noopShutdown)
+	assert.NoError(t, err)
+	svc.unsubscribe()
+
+	config.SetFlag(config.KeyLogLevel, "debug")
+	defer config.ResetFlags()
+
+	assert.NoError(t, config.Reload())
+	assert.Equal(t, log.INFO, svc.logLevel)
+}
+
+func TestReconfigureConcurrently(t *testing.T) {
+	svc, err := New(simpleSetup, noopShutdown)
+	assert.NoError(t, err)
+
+	config.SetFlag(config.KeyShutdownDelay, "5s")
+	defer config.ResetFlags()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			config.Reload()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		svc.shutdownTimeout()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5*time.Second, svc.shutdownTimeout())
+}
@@ -32,7 +32,10 @@ const (
 type (
 	AuthProvider interface {
 		LookupByToken(token string) (*settings.DialSettings, error)
+		LookupByTenantToken(tenant, token string) (*settings.DialSettings, error)
+		LookupByKey(key string) (*settings.DialSettings, error)
 		UpdateStore(ds *settings.DialSettings) error
+		RevokeToken(token string) error
 	}
 )
 
@@ -60,6 +63,9 @@ var (
 	// ErrNoScope indicates that no scope was provided
 	ErrNoScope = errors.New("no scope provided")
 
+	// ErrTenantMismatch indicates that the credentials belong to a different tenant
+	ErrTenantMismatch = errors.New("tenant mismatch")
+
 	authProvider *cloudlib.Provider
 )
 
@@ -98,6 +104,34 @@ func LookupByToken(token string) (*settings.DialSettings, error) {
 	return imp.(AuthProvider).LookupByToken(token)
 }
 
+// LookupByTenantToken only considers credentials registered with the tenant's ProjectID.
+// Without a tenant resolver, an empty tenant is the same as calling LookupByToken(). Once a
+// resolver is configured, an empty tenant is rejected with ErrTenantMismatch.
+func LookupByTenantToken(tenant, token string) (*settings.DialSettings, error) {
+	imp, found := authProvider.Find(TypeAuthProvider)
+	if !found {
+		return nil, ErrInternalAuthError
+	}
+
+	if tenant == "" {
+		if hasTenantResolver() {
+			return nil, ErrTenantMismatch
+		}
+		return imp.(AuthProvider).LookupByToken(token)
+	}
+	return imp.(AuthProvider).LookupByTenantToken(normalizeTenant(tenant), token)
+}
+
+// LookupByKey finds the authorization of a client by its Credentials.Key()
+func LookupByKey(key string) (*settings.DialSettings, error) {
+	imp, found := authProvider.Find(TypeAuthProvider)
+	if !found {
+		return nil, ErrInternalAuthError
+	}
+
+	return imp.(AuthProvider).LookupByKey(strings.ToLower(key))
+}
+
 func UpdateStore(ds *settings.DialSettings) error {
 	imp, found := authProvider.Find(TypeAuthProvider)
 	if !found {
@@ -107,21 +141,62 @@ func UpdateStore(ds *settings.DialSettings) error {
 	return imp.(AuthProvider).UpdateStore(ds)
 }
 
+// RevokeToken removes a token from the store, e.g. on logout. The client itself stays registered.
+func RevokeToken(token string) error {
+	imp, found := authProvider.Find(TypeAuthProvider)
+	if !found {
+		return ErrInternalAuthError
+	}
+
+	return imp.(AuthProvider).RevokeToken(token)
+}
+
 // Auth functionallity
 //
-// CheckAuthorization relies on the presence of a bearer token and validates the
-// matching authorization against a list of requested scopes. If everything checks out,
-// the function returns the authorization or an error otherwise.
+// CheckAuthorization relies on the presence of a bearer token or a session cookie and validates the
+// matching authorization against a list of requested scopes. Requests that were already authenticated
+// by a middleware, e.g. SignatureMiddleware, are checked against the scopes only. If the request is bound
+// to a tenant, the token must belong to the same project. Once a tenant resolver is configured, requests
+// without a tenant are rejected. If everything checks out, the function returns the authorization or an
+// error otherwise.
 func CheckAuthorization(ctx context.Context, c echo.Context, scope string) (*settings.DialSettings, error) {
+	if auth, ok := c.Get(AuthContextKey).(*settings.DialSettings); ok {
+		return checkAuthorization(auth, Tenant(c), scope)
+	}
+
 	token, err := GetBearerToken(c.Request())
+	if err == ErrNoToken {
+		// browsers authenticate with the session cookie instead
+		if token, err = GetSessionToken(c.Request()); err != nil {
+			return nil, ErrNoToken
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	auth, err := LookupByToken(token)
-	if err != nil || auth == nil || !auth.Credentials.IsValid() {
+	tenant := Tenant(c)
+	auth, err := LookupByTenantToken(tenant, token)
+	if err == ErrTenantMismatch {
+		return nil, err
+	}
+	if err != nil {
+		return nil, ErrNotAuthorized
+	}
+
+	return checkAuthorization(auth, tenant, scope)
+}
+
+func checkAuthorization(auth *settings.DialSettings, tenant, scope string) (*settings.DialSettings, error) {
+	if auth == nil || !auth.Credentials.IsValid() {
 		return nil, ErrNotAuthorized
 	}
+	if tenant == "" && hasTenantResolver() {
+		return nil, ErrTenantMismatch // the request must be bound to a tenant
+	}
+	if tenant != "" && normalizeTenant(auth.Credentials.ProjectID) != tenant {
+		return nil, ErrTenantMismatch
+	}
 
 	if hasScope(auth.GetScopes(), ScopeApiAdmin) {
 		return auth, nil
@@ -153,6 +228,18 @@ func GetBearerToken(r *http.Request) (string, error) {
 	return "", ErrNoToken
 }
 
+// errorResponse responds the same way as api.ErrorResponse, the auth package can't use it directly
+func errorResponse(c echo.Context, status int, err error) error {
+	resp := struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  status,
+		Message: err.Error(),
+	}
+	return c.JSON(status, &resp)
+}
+
 // FIXME: this is a VERY simple implementation
 func hasScope(target []string, scope string) bool {
 
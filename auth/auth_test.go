@@ -67,6 +67,44 @@ func TestUpdateStore(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestUpdateStoreReplacesToken(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "replace",
+			ClientID:  "client",
+			Token:     "old-token",
+		},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+
+	ds.Credentials.Token = "new-token"
+	assert.NoError(t, UpdateStore(&ds))
+
+	_, err := LookupByToken("old-token")
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+	found, err := LookupByToken("new-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "client", found.Credentials.ClientID)
+}
+
+func TestRevokeToken(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "revoke",
+			ClientID:  "client",
+			Token:     "revoked-token",
+		},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+
+	assert.NoError(t, RevokeToken("revoked-token"))
+	_, err := LookupByToken("revoked-token")
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+
+	assert.ErrorIs(t, RevokeToken("revoked-token"), ErrTokenNotFound)
+	assert.ErrorIs(t, RevokeToken(""), ErrNoToken)
+}
+
 func TestLookupByToken(t *testing.T) {
 	ds, err := LookupByToken("token")
 	assert.NoError(t, err)
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+const (
+	// FingerprintPrefix marks a certificate id as a SHA-256 fingerprint
+	FingerprintPrefix = "sha256:"
+)
+
+var (
+	// maps certificate fingerprints or subject names to Credentials.Key()
+	certToKey map[string]string
+	cmu       sync.RWMutex // used to protect the above
+)
+
+func init() {
+	certToKey = make(map[string]string)
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a certificate, e.g. 'sha256:4f2a...'.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return FingerprintPrefix + hex.EncodeToString(sum[:])
+}
+
+// RegisterClientCertificate maps a client certificate to a registered client, identified by its
+// Credentials.Key(). The id is either a fingerprint (see CertificateFingerprint) or a subject name,
+// i.e. one of the certificate's SANs (DNS name, email address or URI) or its common name.
+func RegisterClientCertificate(id, key string) {
+	cmu.Lock()
+	defer cmu.Unlock()
+
+	certToKey[strings.ToLower(id)] = strings.ToLower(key)
+}
+
+// UnregisterClientCertificate removes a mapping created with RegisterClientCertificate.
+func UnregisterClientCertificate(id string) {
+	cmu.Lock()
+	defer cmu.Unlock()
+
+	delete(certToKey, strings.ToLower(id))
+}
+
+// LookupByCertificate finds the client a certificate is mapped to. The fingerprint takes precedence
+// over the SANs, which take precedence over the common name.
+func LookupByCertificate(cert *x509.Certificate) (*settings.DialSettings, error) {
+	if cert == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	cmu.RLock()
+	defer cmu.RUnlock()
+
+	for _, id := range certificateIDs(cert) {
+		if key, ok := certToKey[strings.ToLower(id)]; ok {
+			return LookupByKey(key)
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+// ClientCertificateMiddleware authenticates requests that present a verified client certificate
+// which is mapped to a registered client. All other requests are passed on unchanged.
+func ClientCertificateMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cert := verifiedClientCertificate(c.Request())
+			if cert == nil {
+				return next(c)
+			}
+
+			if auth, err := LookupByCertificate(cert); err == nil && auth != nil {
+				c.Set(AuthContextKey, auth)
+			}
+			return next(c)
+		}
+	}
+}
+
+// verifiedClientCertificate returns the leaf certificate if the TLS stack verified its chain
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// certificateIDs returns all ids a certificate can be registered with, in order of precedence
+func certificateIDs(cert *x509.Certificate) []string {
+	ids := []string{CertificateFingerprint(cert)}
+
+	ids = append(ids, cert.DNSNames...)
+	ids = append(ids, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+
+	return ids
+}
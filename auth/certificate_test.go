@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func newTestCertificate(t *testing.T, cn string, emails ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: cn},
+		EmailAddresses: emails,
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert
+}
+
+func TestLookupByCertificate(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "mtls",
+			ClientID:  "client@example.com",
+			Token:     "mtls-token",
+		},
+		DefaultScopes: []string{ScopeApiRead},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+
+	cert1 := newTestCertificate(t, "client1")
+	cert2 := newTestCertificate(t, "client2", "client@example.com")
+	cert3 := newTestCertificate(t, "client3")
+
+	// by fingerprint
+	RegisterClientCertificate(CertificateFingerprint(cert1), ds.Credentials.Key())
+	a, err := LookupByCertificate(cert1)
+	assert.NoError(t, err)
+	assert.Equal(t, ds.Credentials.Key(), a.Credentials.Key())
+
+	// by SAN
+	RegisterClientCertificate("client@example.com", ds.Credentials.Key())
+	a, err = LookupByCertificate(cert2)
+	assert.NoError(t, err)
+	assert.Equal(t, ds.Credentials.Key(), a.Credentials.Key())
+
+	// not mapped
+	_, err = LookupByCertificate(cert3)
+	assert.Error(t, err)
+
+	UnregisterClientCertificate(CertificateFingerprint(cert1))
+	_, err = LookupByCertificate(cert1)
+	assert.Error(t, err)
+}
+
+func TestClientCertificateMiddleware(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "mtls",
+			ClientID:  "middleware",
+			Token:     "mtls-middleware-token",
+		},
+		DefaultScopes: []string{ScopeApiRead},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+
+	cert := newTestCertificate(t, "middleware")
+	RegisterClientCertificate("middleware", ds.Credentials.Key())
+
+	h := ClientCertificateMiddleware()(func(c echo.Context) error {
+		_, err := CheckAuthorization(context.TODO(), c, ScopeApiRead)
+		return err
+	})
+
+	// verified certificate, no bearer token needed
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	assert.NoError(t, h(echo.New().NewContext(req, httptest.NewRecorder())))
+
+	// no certificate
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Error(t, h(echo.New().NewContext(req, httptest.NewRecorder())))
+}
@@ -11,6 +11,12 @@ import (
 type (
 	defaultAuthImpl struct {
 	}
+
+	// authStore holds the lookup tables of one tenant
+	authStore struct {
+		tokenToAuth map[string]*settings.DialSettings
+		idToAuth    map[string]*settings.DialSettings
+	}
 )
 
 var (
@@ -26,17 +32,15 @@ var (
 	// the instance, a singleton
 	theDefaultProvider *defaultAuthImpl
 
-	// different types of lookup tables
-	tokenToAuth map[string]*settings.DialSettings
-	idToAuth    map[string]*settings.DialSettings
-	mu          sync.Mutex // used to protect the above cache
+	// the lookup tables, partitioned by tenant i.e. the ProjectID
+	stores map[string]*authStore
+	mu     sync.Mutex // used to protect the above cache
 )
 
 func init() {
 	// force a reset
 	theDefaultProvider = nil
-	tokenToAuth = make(map[string]*settings.DialSettings)
-	idToAuth = make(map[string]*settings.DialSettings)
+	stores = make(map[string]*authStore)
 
 	// initialize the default in-memory only auth provider
 	authConfig := cloudlib.WithProvider("apikit.default.auth", TypeAuthProvider, NewDefaultProvider)
@@ -63,8 +67,46 @@ func (np *defaultAuthImpl) LookupByToken(token string) (*settings.DialSettings,
 	if token == "" {
 		return nil, ErrNoToken
 	}
-	if a, ok := tokenToAuth[token]; ok {
-		return a, nil
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, store := range stores {
+		if a, ok := store.tokenToAuth[token]; ok {
+			return a, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (np *defaultAuthImpl) LookupByTenantToken(tenant, token string) (*settings.DialSettings, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if store, ok := stores[tenant]; ok {
+		if a, ok := store.tokenToAuth[token]; ok {
+			return a, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (np *defaultAuthImpl) LookupByKey(key string) (*settings.DialSettings, error) {
+	if key == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, store := range stores {
+		if a, ok := store.idToAuth[key]; ok {
+			return a, nil
+		}
 	}
 	return nil, ErrTokenNotFound
 }
@@ -82,31 +124,53 @@ func (np *defaultAuthImpl) UpdateStore(ds *settings.DialSettings) error {
 
 	//observer.LogWithLevel(observer.LevelDebug, fmt.Sprintf("update credentials. t=%s/%s", ds.Credentials.ClientID, ds.Credentials.Token))
 
-	/*
-		// check if the settings already exists
-		if a, ok := np.idToAuth[ds.Credentials.Key()]; ok {
-			// FIXME this needs a change in the flow/logic of the resource_auth.go !
-
-				if a.Credentials.Status == settings.StateAuthorized {
-					_ = observer.ReportError(fmt.Errorf("already authorized. t=%s, state=%d", a.Credentials.Token, a.Credentials.Status))
-					return ErrAlreadyAuthorized
-				}
-
-			// remove from token lookup if the token changed
-			if a.Credentials.Token != ds.Credentials.Token {
-				delete(np.tokenToAuth, a.Credentials.Token)
-			}
-		}
-	*/
-
 	// update to the cache
 	_ds := ds.Clone()
-	tokenToAuth[ds.Credentials.Token] = &_ds
-	idToAuth[ds.Credentials.Key()] = &_ds
+	store := tenantStore(normalizeTenant(ds.Credentials.ProjectID))
+
+	// the previous token of the client is no longer valid, e.g. after a login or a recovery. The
+	// API never overwrites an authorized client with an unauthenticated request, see InitEndpoint.
+	if a, ok := store.idToAuth[ds.Credentials.Key()]; ok && a.Credentials.Token != ds.Credentials.Token {
+		delete(store.tokenToAuth, a.Credentials.Token)
+	}
+	store.tokenToAuth[ds.Credentials.Token] = &_ds
+	store.idToAuth[ds.Credentials.Key()] = &_ds
 
 	return nil
 }
 
+func (np *defaultAuthImpl) RevokeToken(token string) error {
+	if token == "" {
+		return ErrNoToken
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, store := range stores {
+		if _, ok := store.tokenToAuth[token]; ok {
+			delete(store.tokenToAuth, token)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
 func (np *defaultAuthImpl) Close() error {
 	return nil
 }
+
+// tenantStore returns the lookup tables of a tenant, creating them if needed. Callers must hold mu.
+func tenantStore(tenant string) *authStore {
+	if store, ok := stores[tenant]; ok {
+		return store
+	}
+
+	store := &authStore{
+		tokenToAuth: make(map[string]*settings.DialSettings),
+		idToAuth:    make(map[string]*settings.DialSettings),
+	}
+	stores[tenant] = store
+
+	return store
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+// Account recovery: the passphrase (mnemonic) created by 'auth init' deterministically derives an
+// Ed25519 key pair. Its public key, the verifier, is registered with the client's credentials. To
+// recover, a client asks for a challenge and answers it with a signature made with the private key,
+// proving that it knows the passphrase without ever sending it.
+
+const (
+	// OptionRecoveryKey holds the verifier of the client's passphrase, see RecoveryVerifier
+	OptionRecoveryKey = "RecoveryKey"
+
+	// ChallengeExpiresAfter is the time in seconds a recovery challenge can be answered
+	ChallengeExpiresAfter = 300
+
+	// separates the signatures of challenges from anything else signed with the key
+	recoveryContext = "apikit recovery"
+)
+
+type (
+	// challenge is an open recovery challenge
+	challenge struct {
+		value   string
+		expires int64
+	}
+)
+
+var (
+	// ErrInvalidRecoveryKey indicates that a verifier is not an Ed25519 public key
+	ErrInvalidRecoveryKey = errors.New("invalid recovery key")
+	// ErrRecoveryFailed indicates that the challenge was not answered correctly or has expired
+	ErrRecoveryFailed = errors.New("recovery failed")
+
+	// open challenges by Credentials.Key()
+	challenges = make(map[string]challenge)
+	chmu       sync.Mutex // protects challenges
+)
+
+// RecoveryKey derives the recovery key of a client from its passphrase
+func RecoveryKey(projectID, clientID, mnemonic string) (ed25519.PrivateKey, error) {
+	phrase := strings.Join(strings.Fields(mnemonic), " ")
+	salt := strings.ToLower(projectID + "." + clientID)
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(phrase), []byte(salt), []byte(recoveryContext)), seed); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// RecoveryVerifier returns the public part of the recovery key, it is safe to store it on the server
+func RecoveryVerifier(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// ValidRecoveryVerifier checks that a verifier can be used with VerifyRecovery
+func ValidRecoveryVerifier(verifier string) error {
+	pub, err := base64.StdEncoding.DecodeString(verifier)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return ErrInvalidRecoveryKey
+	}
+	return nil
+}
+
+// SignChallenge answers a challenge from NewRecoveryChallenge
+func SignChallenge(key ed25519.PrivateKey, challenge string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, challengeMessage(challenge)))
+}
+
+// NewRecoveryChallenge creates a challenge for the client with the Credentials.Key() provided.
+// Any earlier challenge of the client is replaced.
+func NewRecoveryChallenge(key string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(nonce)
+
+	chmu.Lock()
+	defer chmu.Unlock()
+
+	// forget about challenges nobody answered
+	now := stdlib.Now()
+	for k, c := range challenges {
+		if c.expires < now {
+			delete(challenges, k)
+		}
+	}
+	challenges[strings.ToLower(key)] = challenge{value: value, expires: now + ChallengeExpiresAfter}
+
+	return value, nil
+}
+
+// VerifyRecovery checks the answer to the client's open challenge with the verifier registered
+// for the client. A challenge can only be answered once, right or wrong.
+func VerifyRecovery(verifier, key, value, signature string) error {
+	chmu.Lock()
+	c, found := challenges[strings.ToLower(key)]
+	delete(challenges, strings.ToLower(key))
+	chmu.Unlock()
+
+	if !found || c.value != value || c.expires < stdlib.Now() {
+		return ErrRecoveryFailed
+	}
+
+	if err := ValidRecoveryVerifier(verifier); err != nil {
+		return err
+	}
+	pub, _ := base64.StdEncoding.DecodeString(verifier)
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(pub, challengeMessage(value), sig) {
+		return ErrRecoveryFailed
+	}
+	return nil
+}
+
+func challengeMessage(challenge string) []byte {
+	return []byte(recoveryContext + ":" + challenge)
+}
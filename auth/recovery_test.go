@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+const testMnemonic = "hard trend birth pioneer hero immune apology cook foam hurt tattoo artist"
+
+func TestRecoveryKey(t *testing.T) {
+	key, err := RecoveryKey("project", "me@example.com", testMnemonic)
+	assert.NoError(t, err)
+
+	// the same passphrase always derives the same key
+	same, err := RecoveryKey("Project", "me@example.com", "  hard trend birth pioneer hero immune apology cook foam hurt  tattoo artist ")
+	assert.NoError(t, err)
+	assert.Equal(t, RecoveryVerifier(key), RecoveryVerifier(same))
+	assert.NoError(t, ValidRecoveryVerifier(RecoveryVerifier(key)))
+
+	other, err := RecoveryKey("project", "you@example.com", testMnemonic)
+	assert.NoError(t, err)
+	assert.NotEqual(t, RecoveryVerifier(key), RecoveryVerifier(other))
+
+	assert.ErrorIs(t, ValidRecoveryVerifier("not base64"), ErrInvalidRecoveryKey)
+	assert.ErrorIs(t, ValidRecoveryVerifier("c2hvcnQ="), ErrInvalidRecoveryKey)
+}
+
+func TestVerifyRecovery(t *testing.T) {
+	key, _ := RecoveryKey("project", "me@example.com", testMnemonic)
+	other, _ := RecoveryKey("project", "me@example.com", "another passphrase")
+	verifier := RecoveryVerifier(key)
+
+	value, err := NewRecoveryChallenge("project.me@example.com")
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyRecovery(verifier, "PROJECT.me@example.com", value, SignChallenge(key, value)))
+
+	// a value can only be answered once
+	assert.ErrorIs(t, VerifyRecovery(verifier, "project.me@example.com", value, SignChallenge(key, value)), ErrRecoveryFailed)
+
+	// the wrong key uses up the value too
+	value, _ = NewRecoveryChallenge("project.me@example.com")
+	assert.ErrorIs(t, VerifyRecovery(verifier, "project.me@example.com", value, SignChallenge(other, value)), ErrRecoveryFailed)
+	assert.ErrorIs(t, VerifyRecovery(verifier, "project.me@example.com", value, SignChallenge(key, value)), ErrRecoveryFailed)
+
+	// only the latest value counts
+	first, _ := NewRecoveryChallenge("project.me@example.com")
+	_, _ = NewRecoveryChallenge("project.me@example.com")
+	assert.ErrorIs(t, VerifyRecovery(verifier, "project.me@example.com", first, SignChallenge(key, first)), ErrRecoveryFailed)
+
+	// expired
+	value, _ = NewRecoveryChallenge("project.me@example.com")
+	chmu.Lock()
+	challenges["project.me@example.com"] = challenge{value: value, expires: stdlib.Now() - 1}
+	chmu.Unlock()
+	assert.ErrorIs(t, VerifyRecovery(verifier, "project.me@example.com", value, SignChallenge(key, value)), ErrRecoveryFailed)
+
+	// no verifier registered
+	value, _ = NewRecoveryChallenge("project.me@example.com")
+	assert.ErrorIs(t, VerifyRecovery("", "project.me@example.com", value, SignChallenge(key, value)), ErrInvalidRecoveryKey)
+}
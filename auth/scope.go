@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	// all scopes known to the service
+	knownScopes = map[string]bool{
+		ScopeAnonymous:   true,
+		ScopeApiRead:     true,
+		ScopeApiWrite:    true,
+		ScopeApiEdit:     true,
+		ScopeApiCreate:   true,
+		ScopeApiDelete:   true,
+		ScopeApiAdmin:    true,
+		ScopeApiNoAccess: true,
+	}
+	scmu sync.RWMutex // used to protect the above
+)
+
+// RegisterScopes adds service specific scopes to the known scopes.
+func RegisterScopes(scopes ...string) {
+	scmu.Lock()
+	defer scmu.Unlock()
+
+	for _, s := range scopes {
+		knownScopes[s] = true
+	}
+}
+
+// IsKnownScope returns true if the scope is one of the default scopes or was registered with RegisterScopes.
+func IsKnownScope(scope string) bool {
+	scmu.RLock()
+	defer scmu.RUnlock()
+
+	return knownScopes[scope]
+}
+
+// KnownScopes returns all known scopes, sorted.
+func KnownScopes() []string {
+	scmu.RLock()
+	defer scmu.RUnlock()
+
+	scopes := make([]string, 0, len(knownScopes))
+	for s := range knownScopes {
+		scopes = append(scopes, s)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
@@ -0,0 +1,17 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKnownScopes(t *testing.T) {
+	assert.True(t, IsKnownScope(ScopeApiRead))
+	assert.False(t, IsKnownScope("billing:read"))
+
+	RegisterScopes("billing:read")
+	assert.True(t, IsKnownScope("billing:read"))
+	assert.Contains(t, KnownScopes(), "billing:read")
+	assert.Contains(t, KnownScopes(), ScopeApiAdmin)
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/txsvc/stdlib/v2"
+)
+
+const (
+	// SessionCookieName is the name of the encrypted session cookie
+	SessionCookieName = "apikit_session"
+	// CSRFCookieName is the name of the cookie holding the CSRF token, readable by scripts
+	CSRFCookieName = "apikit_csrf"
+	// CSRFHeader is the header that has to echo the CSRF token on unsafe requests
+	CSRFHeader = "X-CSRF-Token"
+
+	// SessionExpiresAfter is the lifetime of a session, in minutes
+	SessionExpiresAfter = 24 * 60
+)
+
+type (
+	// sessionPayload is what gets encrypted into the session cookie
+	sessionPayload struct {
+		Token   string `json:"t"`
+		Expires int64  `json:"e"`
+	}
+)
+
+var (
+	// ErrNoSession indicates that no session cookie was provided
+	ErrNoSession = errors.New("no session")
+	// ErrInvalidSession indicates that the session cookie could not be decrypted or is expired
+	ErrInvalidSession = errors.New("invalid session")
+	// ErrInvalidCSRFToken indicates that the CSRF token is missing or does not match the session
+	ErrInvalidCSRFToken = errors.New("invalid csrf token")
+
+	// derived session keys, the first one is used to encrypt, all of them to decrypt
+	sessionKeys [][]byte
+	smu         sync.RWMutex // used to protect the above
+)
+
+// SetSessionKeys configures the keys used to encrypt session cookies. The first key is used to
+// encrypt new cookies, all others are only used to decrypt existing ones. This allows keys to be
+// rotated without logging out all users.
+func SetSessionKeys(keys ...string) {
+	smu.Lock()
+	defer smu.Unlock()
+
+	sessionKeys = make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		key := sha256.Sum256([]byte(k))
+		sessionKeys = append(sessionKeys, key[:])
+	}
+}
+
+// NewSessionCookie creates an encrypted cookie that carries the token. Set secure if the
+// session is served via https.
+func NewSessionCookie(token string, secure bool) (*http.Cookie, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	p, err := json.Marshal(&sessionPayload{
+		Token:   token,
+		Expires: stdlib.IncT(stdlib.Now(), SessionExpiresAfter),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	smu.RLock()
+	defer smu.RUnlock()
+
+	if len(sessionKeys) == 0 {
+		return nil, ErrInternalAuthError
+	}
+	value, err := seal(sessionKeys[0], p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   SessionExpiresAfter * 60,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
+
+// NewCSRFCookie creates the cookie carrying the CSRF token that belongs to the session token.
+func NewCSRFCookie(token string, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    CSRFToken(token),
+		Path:     "/",
+		MaxAge:   SessionExpiresAfter * 60,
+		Secure:   secure,
+		HttpOnly: false, // scripts need to read this one
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// ClearSessionCookies returns cookies that remove the session and CSRF cookies from the browser.
+// They have the same attributes as the cookies they replace, set secure if served via https.
+func ClearSessionCookies(secure bool) []*http.Cookie {
+	return []*http.Cookie{
+		{Name: SessionCookieName, Value: "", Path: "/", MaxAge: -1, Secure: secure, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		{Name: CSRFCookieName, Value: "", Path: "/", MaxAge: -1, Secure: secure, HttpOnly: false, SameSite: http.SameSiteStrictMode},
+	}
+}
+
+// GetSessionToken returns the token stored in the session cookie, if there is a valid one.
+func GetSessionToken(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", ErrNoSession
+	}
+
+	smu.RLock()
+	defer smu.RUnlock()
+
+	for _, key := range sessionKeys {
+		p, err := open(key, cookie.Value)
+		if err != nil {
+			continue // try the next key
+		}
+
+		var session sessionPayload
+		if err := json.Unmarshal(p, &session); err != nil {
+			return "", ErrInvalidSession
+		}
+		if session.Expires < stdlib.Now() {
+			return "", ErrInvalidSession
+		}
+		return session.Token, nil
+	}
+
+	return "", ErrInvalidSession
+}
+
+// CSRFToken derives the CSRF token from a session token, using the current session key.
+func CSRFToken(token string) string {
+	smu.RLock()
+	defer smu.RUnlock()
+
+	if len(sessionKeys) == 0 {
+		return ""
+	}
+	return csrfToken(sessionKeys[0], token)
+}
+
+// CSRFMiddleware protects cookie-authenticated requests with unsafe methods. Requests using a
+// bearer token are not affected as browsers never add the Authorization header on their own.
+func CSRFMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				return next(c)
+			}
+			if r.Header.Get("Authorization") != "" {
+				return next(c)
+			}
+			token, err := GetSessionToken(r)
+			if err != nil {
+				return next(c) // not a session, leave it to CheckAuthorization
+			}
+
+			if !validCSRFToken(token, r.Header.Get(CSRFHeader)) {
+				return errorResponse(c, http.StatusForbidden, ErrInvalidCSRFToken)
+			}
+			return next(c)
+		}
+	}
+}
+
+func validCSRFToken(token, csrf string) bool {
+	if csrf == "" {
+		return false
+	}
+
+	smu.RLock()
+	defer smu.RUnlock()
+
+	for _, key := range sessionKeys {
+		if hmac.Equal([]byte(csrf), []byte(csrfToken(key, token))) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfToken(key []byte, token string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("csrf:" + token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// seal encrypts and authenticates p with AES-GCM
+func seal(key, p []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, p, nil)), nil
+}
+
+// open reverses seal
+func open(key []byte, value string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrInvalidSession
+	}
+
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func TestSessionCookie(t *testing.T) {
+	SetSessionKeys("current-key")
+
+	cookie, err := NewSessionCookie("session-token", false)
+	assert.NoError(t, err)
+	assert.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+
+	token, err := GetSessionToken(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "session-token", token)
+
+	// rotate the key, the old cookie remains valid
+	SetSessionKeys("next-key", "current-key")
+	token, err = GetSessionToken(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "session-token", token)
+
+	// retire the key, the cookie becomes invalid
+	SetSessionKeys("next-key")
+	_, err = GetSessionToken(req)
+	assert.Equal(t, ErrInvalidSession, err)
+
+	// no cookie at all
+	_, err = GetSessionToken(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, ErrNoSession, err)
+}
+
+func TestClearSessionCookies(t *testing.T) {
+	SetSessionKeys("current-key")
+
+	session, err := NewSessionCookie("session-token", true)
+	assert.NoError(t, err)
+	csrf := NewCSRFCookie("session-token", true)
+
+	cleared := ClearSessionCookies(true)
+	if assert.Len(t, cleared, 2) {
+		for i, c := range []*http.Cookie{session, csrf} {
+			assert.Equal(t, c.Name, cleared[i].Name)
+			assert.Equal(t, c.Path, cleared[i].Path)
+			assert.Equal(t, c.Secure, cleared[i].Secure)
+			assert.Equal(t, c.HttpOnly, cleared[i].HttpOnly)
+			assert.Equal(t, c.SameSite, cleared[i].SameSite)
+			assert.Equal(t, -1, cleared[i].MaxAge)
+		}
+	}
+}
+
+func TestCheckAuthorizationWithSession(t *testing.T) {
+	SetSessionKeys("session-key")
+
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "session",
+			ClientID:  "client",
+			Token:     "session-auth-token",
+		},
+		DefaultScopes: []string{ScopeApiRead},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+
+	cookie, err := NewSessionCookie("session-auth-token", false)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	a, err := CheckAuthorization(context.TODO(), c, ScopeApiRead)
+	assert.NoError(t, err)
+	assert.NotNil(t, a)
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	SetSessionKeys("csrf-key")
+
+	cookie, err := NewSessionCookie("csrf-token", false)
+	assert.NoError(t, err)
+
+	e := echo.New()
+	h := CSRFMiddleware()(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	// unsafe method with a session cookie but without the CSRF header
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, h(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.JSONEq(t, `{"status":403,"message":"invalid csrf token"}`, rec.Body.String())
+
+	// same, with the CSRF header
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(CSRFHeader, CSRFToken("csrf-token"))
+	assert.NoError(t, h(e.NewContext(req, httptest.NewRecorder())))
+
+	// safe method
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	assert.NoError(t, h(e.NewContext(req, httptest.NewRecorder())))
+
+	// bearer token authentication
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("Authorization", "Bearer csrf-token")
+	assert.NoError(t, h(e.NewContext(req, httptest.NewRecorder())))
+}
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+)
+
+// Request signing, loosely following HTTP Message Signatures (RFC 9421) with HMAC-SHA256.
+// The client signs the method, path, query, content type and a digest of the body,
+// together with a timestamp and a nonce. The server verifies the signature, rejects
+// requests outside of the allowed clock skew and requests it has seen before.
+
+const (
+	// headers used to sign a request
+	SignatureHeader      = "Signature"
+	SignatureInputHeader = "Signature-Input"
+	ContentDigestHeader  = "Content-Digest"
+
+	// SignatureLabel is the label of the one signature a client adds
+	SignatureLabel = "sig1"
+	// SignatureAlgorithm is the only supported algorithm
+	SignatureAlgorithm = "hmac-sha256"
+
+	// MaxClockSkew is the maximum difference between the client's and the server's clock, in seconds
+	MaxClockSkew = 300
+	// DefaultMaxSignedBodySize is the default size limit of signed request bodies, see SetMaxSignedBodySize()
+	DefaultMaxSignedBodySize = 10 << 20
+
+	// AuthContextKey is used to store the authenticated DialSettings in the echo context
+	AuthContextKey = "apikit.auth"
+)
+
+var (
+	// ErrInvalidSignature indicates that the signature or its parameters could not be verified
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrSignatureExpired indicates that the signature was created outside of the allowed clock skew
+	ErrSignatureExpired = errors.New("signature expired")
+	// ErrReplayedRequest indicates that the signature's nonce was already used
+	ErrReplayedRequest = errors.New("replayed request")
+	// ErrBodyTooLarge indicates that the body of a signed request exceeds the size limit
+	ErrBodyTooLarge = errors.New("request body too large")
+
+	// the covered components, in order
+	signatureComponents = []string{"@method", "@path", "@query", "content-type", "content-digest"}
+
+	// nonces seen within the last 2*MaxClockSkew seconds
+	nonces map[string]int64
+	nmu    sync.Mutex // used to protect the above
+
+	// the body is read to verify its digest, before the client is known
+	maxSignedBodySize atomic.Int64
+)
+
+func init() {
+	nonces = make(map[string]int64)
+	maxSignedBodySize.Store(DefaultMaxSignedBodySize)
+}
+
+// SetMaxSignedBodySize limits the size of signed request bodies, in bytes. Larger requests are
+// rejected by SignatureMiddleware with 413 Request Entity Too Large.
+func SetMaxSignedBodySize(n int64) {
+	if n <= 0 {
+		n = DefaultMaxSignedBodySize
+	}
+	maxSignedBodySize.Store(n)
+}
+
+// SigningSecret returns the secret shared between client and server that is used to sign requests.
+func SigningSecret(cred *settings.Credentials) string {
+	if cred == nil {
+		return ""
+	}
+	if cred.ClientSecret != "" {
+		return cred.ClientSecret
+	}
+	return cred.Token
+}
+
+// SignRequest adds the Content-Digest, Signature-Input and Signature headers to the request.
+func SignRequest(req *http.Request, keyID, secret string) error {
+	if keyID == "" || secret == "" {
+		return ErrInvalidCredentials
+	}
+
+	digest, err := contentDigest(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(ContentDigestHeader, digest)
+
+	nonce, err := stdlib.ShortUUID()
+	if err != nil {
+		return err
+	}
+	params := signatureParams(stdlib.Now(), nonce, keyID)
+
+	req.Header.Set(SignatureInputHeader, fmt.Sprintf("%s=%s", SignatureLabel, params))
+	req.Header.Set(SignatureHeader, fmt.Sprintf("%s=:%s:", SignatureLabel, sign(secret, signatureBase(req, params))))
+
+	return nil
+}
+
+// VerifyRequest verifies the signature of a request and returns the authorization of the signing client.
+func VerifyRequest(r *http.Request) (*settings.DialSettings, error) {
+	params, created, nonce, keyID, err := parseSignatureInput(r.Header.Get(SignatureInputHeader))
+	if err != nil {
+		return nil, err
+	}
+	signature, err := parseSignature(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	now := stdlib.Now()
+	if created < now-MaxClockSkew || created > now+MaxClockSkew {
+		return nil, ErrSignatureExpired
+	}
+
+	// the body must match the digest, which is covered by the signature
+	digest, err := contentDigest(r)
+	if err != nil {
+		return nil, err
+	}
+	if digest != r.Header.Get(ContentDigestHeader) {
+		return nil, ErrInvalidSignature
+	}
+
+	auth, err := LookupByKey(keyID)
+	if err != nil || auth == nil || !auth.Credentials.IsValid() {
+		return nil, ErrNotAuthorized
+	}
+	expected := sign(SigningSecret(auth.Credentials), signatureBase(r, params))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	// only check for replays once the signature is known to be valid
+	if !useNonce(keyID+":"+nonce, now) {
+		return nil, ErrReplayedRequest
+	}
+
+	return auth, nil
+}
+
+// SignatureMiddleware authenticates signed requests. Requests without a signature are passed on
+// unchanged, so that endpoints can still use a bearer token or session cookie.
+func SignatureMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(SignatureInputHeader) == "" {
+				return next(c)
+			}
+
+			// the body is read before the signature is verified
+			r := c.Request()
+			limit := maxSignedBodySize.Load()
+			if r.ContentLength > limit {
+				return errorResponse(c, http.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+			}
+			r.Body = http.MaxBytesReader(c.Response(), r.Body, limit)
+
+			auth, err := VerifyRequest(r)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return errorResponse(c, http.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+			}
+			if err != nil {
+				return errorResponse(c, http.StatusUnauthorized, err)
+			}
+			c.Set(AuthContextKey, auth)
+
+			return next(c)
+		}
+	}
+}
+
+func signatureParams(created int64, nonce, keyID string) string {
+	components := make([]string, len(signatureComponents))
+	for i, c := range signatureComponents {
+		components[i] = strconv.Quote(c)
+	}
+	return fmt.Sprintf("(%s);created=%d;nonce=%s;keyid=%s;alg=%s", strings.Join(components, " "), created, strconv.Quote(nonce), strconv.Quote(keyID), strconv.Quote(SignatureAlgorithm))
+}
+
+func signatureBase(r *http.Request, params string) string {
+	var b strings.Builder
+
+	for _, c := range signatureComponents {
+		var value string
+		switch c {
+		case "@method":
+			value = r.Method
+		case "@path":
+			value = r.URL.EscapedPath()
+			if value == "" {
+				value = "/"
+			}
+		case "@query":
+			value = "?" + r.URL.RawQuery
+		default:
+			value = strings.TrimSpace(r.Header.Get(c))
+		}
+		fmt.Fprintf(&b, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+
+	return b.String()
+}
+
+func sign(secret, base string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// contentDigest returns the sha-256 digest of the body, leaving the body readable
+func contentDigest(r *http.Request) (string, error) {
+	var body []byte
+
+	if r.Body != nil && r.Body != http.NoBody {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("sha-256=:%s:", base64.StdEncoding.EncodeToString(sum[:])), nil
+}
+
+// parseSignatureInput parses the parameters created by signatureParams
+func parseSignatureInput(input string) (params string, created int64, nonce, keyID string, err error) {
+	label, params, found := strings.Cut(input, "=")
+	if !found || label != SignatureLabel {
+		return "", 0, "", "", ErrInvalidSignature
+	}
+
+	// the list of components has to match exactly
+	expected := signatureParams(0, "", "")
+	components := expected[:strings.Index(expected, ";")]
+	if !strings.HasPrefix(params, components+";") {
+		return "", 0, "", "", ErrInvalidSignature
+	}
+
+	alg := ""
+	for _, p := range strings.Split(strings.TrimPrefix(params, components+";"), ";") {
+		k, v, _ := strings.Cut(p, "=")
+		switch k {
+		case "created":
+			created, err = strconv.ParseInt(v, 10, 64)
+		case "nonce":
+			nonce, err = strconv.Unquote(v)
+		case "keyid":
+			keyID, err = strconv.Unquote(v)
+		case "alg":
+			alg, err = strconv.Unquote(v)
+		}
+		if err != nil {
+			return "", 0, "", "", ErrInvalidSignature
+		}
+	}
+
+	if created == 0 || nonce == "" || keyID == "" || alg != SignatureAlgorithm {
+		return "", 0, "", "", ErrInvalidSignature
+	}
+	return params, created, nonce, keyID, nil
+}
+
+func parseSignature(sig string) (string, error) {
+	label, value, found := strings.Cut(sig, "=")
+	if !found || label != SignatureLabel || len(value) < 2 || !strings.HasPrefix(value, ":") || !strings.HasSuffix(value, ":") {
+		return "", ErrInvalidSignature
+	}
+	return strings.Trim(value, ":"), nil
+}
+
+// useNonce records the nonce and returns false if it was seen before
+func useNonce(nonce string, now int64) bool {
+	nmu.Lock()
+	defer nmu.Unlock()
+
+	// forget about nonces that can no longer pass the clock skew check
+	for n, t := range nonces {
+		if t < now-2*MaxClockSkew {
+			delete(nonces, n)
+		}
+	}
+
+	if _, found := nonces[nonce]; found {
+		return false
+	}
+	nonces[nonce] = now
+
+	return true
+}
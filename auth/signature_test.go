@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+)
+
+func registerSigningClient(t *testing.T) *settings.DialSettings {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "signature",
+			ClientID:  "client",
+			Token:     "signing-token",
+		},
+		DefaultScopes: []string{ScopeApiRead},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+	return &ds
+}
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	ds := registerSigningClient(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/a/v1/things?x=1", bytes.NewBufferString(`{"a":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+
+	assert.NotEmpty(t, req.Header.Get(SignatureHeader))
+	assert.NotEmpty(t, req.Header.Get(SignatureInputHeader))
+	assert.NotEmpty(t, req.Header.Get(ContentDigestHeader))
+
+	a, err := VerifyRequest(req)
+	assert.NoError(t, err)
+	assert.NotNil(t, a)
+
+	// the same request again is a replay
+	_, err = VerifyRequest(req)
+	assert.Equal(t, ErrReplayedRequest, err)
+}
+
+func TestVerifyTamperedRequest(t *testing.T) {
+	ds := registerSigningClient(t)
+
+	// modified body
+	req := httptest.NewRequest(http.MethodPost, "/a/v1/things", bytes.NewBufferString(`{"a":1}`))
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+	req.Body = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"a":2}`)).Body
+	_, err := VerifyRequest(req)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	// modified path
+	req = httptest.NewRequest(http.MethodGet, "/a/v1/things", nil)
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+	req.URL.Path = "/a/v1/other"
+	_, err = VerifyRequest(req)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	// wrong secret
+	req = httptest.NewRequest(http.MethodGet, "/a/v1/things", nil)
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), "not-the-secret"))
+	_, err = VerifyRequest(req)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	// unknown client
+	req = httptest.NewRequest(http.MethodGet, "/a/v1/things", nil)
+	assert.NoError(t, SignRequest(req, "signature.unknown", "secret"))
+	_, err = VerifyRequest(req)
+	assert.Equal(t, ErrNotAuthorized, err)
+}
+
+func TestVerifyClockSkew(t *testing.T) {
+	ds := registerSigningClient(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/a/v1/things", nil)
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+
+	// pretend the request was signed a long time ago
+	created := stdlib.Now() - 2*MaxClockSkew
+	params := signatureParams(created, "nonce", ds.Credentials.Key())
+	req.Header.Set(SignatureInputHeader, fmt.Sprintf("%s=%s", SignatureLabel, params))
+	req.Header.Set(SignatureHeader, fmt.Sprintf("%s=:%s:", SignatureLabel, sign(SigningSecret(ds.Credentials), signatureBase(req, params))))
+
+	_, err := VerifyRequest(req)
+	assert.Equal(t, ErrSignatureExpired, err)
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	ds := registerSigningClient(t)
+
+	e := echo.New()
+	h := SignatureMiddleware()(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/a/v1/things", bytes.NewBufferString(`{"a":1}`))
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+	rec := httptest.NewRecorder()
+	assert.NoError(t, h(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// the same error body as any other API error
+	rec = httptest.NewRecorder()
+	assert.NoError(t, h(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"status":401,"message":"replayed request"}`, rec.Body.String())
+
+	// bodies above the limit are not read
+	SetMaxSignedBodySize(4)
+	defer SetMaxSignedBodySize(0)
+
+	req = httptest.NewRequest(http.MethodPost, "/a/v1/things", bytes.NewBufferString(`{"a":1}`))
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+	rec = httptest.NewRecorder()
+	assert.NoError(t, h(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// also without a Content-Length
+	req = httptest.NewRequest(http.MethodPost, "/a/v1/things", bytes.NewBufferString(`{"a":1}`))
+	assert.NoError(t, SignRequest(req, ds.Credentials.Key(), SigningSecret(ds.Credentials)))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	assert.NoError(t, h(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// TenantHeader is the default header used to select the tenant of a request
+	TenantHeader = "X-Tenant-ID"
+	// TenantContextKey is used to store the resolved tenant in the echo context
+	TenantContextKey = "apikit.tenant"
+)
+
+type (
+	// TenantResolver extracts the tenant (i.e. the ProjectID) from a request.
+	// An empty string means that the request is not bound to a specific tenant.
+	TenantResolver func(c echo.Context) string
+)
+
+var (
+	// the resolver used by Tenant() and CheckAuthorization()
+	tenantResolver TenantResolver
+	// per-tenant default scopes
+	tenantScopes map[string][]string
+	tmu          sync.RWMutex // used to protect the above
+)
+
+func init() {
+	tenantScopes = make(map[string][]string)
+}
+
+// SetTenantResolver configures how the tenant of a request is determined. Once a resolver is set,
+// requests that resolve to no tenant are not authorized. Passing nil disables tenant checks,
+// i.e. tokens are valid across all projects.
+func SetTenantResolver(r TenantResolver) {
+	tmu.Lock()
+	defer tmu.Unlock()
+
+	tenantResolver = r
+}
+
+// hasTenantResolver returns true if requests must be bound to a tenant
+func hasTenantResolver() bool {
+	tmu.RLock()
+	defer tmu.RUnlock()
+
+	return tenantResolver != nil
+}
+
+// TenantFromHeader resolves the tenant from a request header, e.g. TenantHeader.
+func TenantFromHeader(header string) TenantResolver {
+	return func(c echo.Context) string {
+		return normalizeTenant(c.Request().Header.Get(header))
+	}
+}
+
+// TenantFromHost resolves the tenant from the first label of the request's host name,
+// e.g. 'acme.api.example.com' resolves to 'acme'. Plain host names and IPs resolve to "".
+func TenantFromHost() TenantResolver {
+	return func(c echo.Context) string {
+		host := c.Request().Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) != nil {
+			return ""
+		}
+
+		parts := strings.Split(host, ".")
+		if len(parts) < 3 {
+			return ""
+		}
+		return normalizeTenant(parts[0])
+	}
+}
+
+// TenantFromPathPrefix resolves the tenant from the path segment following prefix,
+// e.g. with prefix '/t', the path '/t/acme/a/v1/auth' resolves to 'acme'.
+func TenantFromPathPrefix(prefix string) TenantResolver {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+
+	return func(c echo.Context) string {
+		path := c.Request().URL.Path
+		if !strings.HasPrefix(path, prefix) {
+			return ""
+		}
+
+		tenant, _, _ := strings.Cut(strings.TrimPrefix(path, prefix), "/")
+		return normalizeTenant(tenant)
+	}
+}
+
+// TenantMiddleware resolves the tenant once and makes it available to all handlers via Tenant().
+func TenantMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			Tenant(c)
+			return next(c)
+		}
+	}
+}
+
+// Tenant returns the tenant of the current request or "" if the request is not bound to a tenant.
+func Tenant(c echo.Context) string {
+	if t, ok := c.Get(TenantContextKey).(string); ok {
+		return t
+	}
+
+	tmu.RLock()
+	r := tenantResolver
+	tmu.RUnlock()
+
+	if r == nil {
+		return ""
+	}
+
+	tenant := r(c)
+	c.Set(TenantContextKey, tenant)
+
+	return tenant
+}
+
+// SetTenantDefaultScopes sets the scopes that new clients of a tenant are registered with.
+// Calling it without scopes removes the tenant specific defaults.
+func SetTenantDefaultScopes(tenant string, scopes ...string) {
+	tmu.Lock()
+	defer tmu.Unlock()
+
+	if len(scopes) == 0 {
+		delete(tenantScopes, normalizeTenant(tenant))
+		return
+	}
+
+	s := make([]string, len(scopes))
+	copy(s, scopes)
+	tenantScopes[normalizeTenant(tenant)] = s
+}
+
+// TenantDefaultScopes returns the tenant specific default scopes or nil if there are none.
+func TenantDefaultScopes(tenant string) []string {
+	tmu.RLock()
+	defer tmu.RUnlock()
+
+	if scopes, ok := tenantScopes[normalizeTenant(tenant)]; ok {
+		s := make([]string, len(scopes))
+		copy(s, scopes)
+		return s
+	}
+	return nil
+}
+
+// normalizeTenant makes the tenant comparable with Credentials.Key(), which is lower case
+func normalizeTenant(tenant string) string {
+	return strings.ToLower(strings.TrimSpace(tenant))
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func newTestContext(target string, header http.Header) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v[0])
+	}
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestTenantResolvers(t *testing.T) {
+	c := newTestContext("http://acme.api.example.com/ping", http.Header{TenantHeader: []string{"Tenant-A"}})
+	assert.Equal(t, "tenant-a", TenantFromHeader(TenantHeader)(c))
+	assert.Equal(t, "acme", TenantFromHost()(c))
+
+	c = newTestContext("http://localhost:8080/t/acme/a/v1/auth", nil)
+	assert.Equal(t, "", TenantFromHost()(c))
+	assert.Equal(t, "acme", TenantFromPathPrefix("/t")(c))
+	assert.Equal(t, "", TenantFromPathPrefix("/x")(c))
+}
+
+func TestTenantDefaultScopes(t *testing.T) {
+	assert.Nil(t, TenantDefaultScopes("acme"))
+
+	SetTenantDefaultScopes("ACME", ScopeApiRead, ScopeApiWrite)
+	assert.Equal(t, []string{ScopeApiRead, ScopeApiWrite}, TenantDefaultScopes("acme"))
+
+	SetTenantDefaultScopes("acme")
+	assert.Nil(t, TenantDefaultScopes("acme"))
+}
+
+func TestTenantIsolation(t *testing.T) {
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "tenant-a",
+			ClientID:  "client",
+			Token:     "tenant-a-token",
+		},
+		DefaultScopes: []string{ScopeApiRead},
+	}
+	assert.NoError(t, UpdateStore(&ds))
+
+	// found in its own tenant, but not in any other
+	a, err := LookupByTenantToken("TENANT-A", "tenant-a-token")
+	assert.NoError(t, err)
+	assert.NotNil(t, a)
+
+	a, err = LookupByTenantToken("tenant-b", "tenant-a-token")
+	assert.Error(t, err)
+	assert.Nil(t, a)
+
+	// without tenant checks, the token is valid everywhere
+	SetTenantResolver(nil)
+	c := newTestContext("/ping", http.Header{"Authorization": []string{"Bearer tenant-a-token"}})
+	a, err = CheckAuthorization(context.TODO(), c, ScopeApiRead)
+	assert.NoError(t, err)
+	assert.NotNil(t, a)
+
+	// with tenant checks enabled
+	SetTenantResolver(TenantFromHeader(TenantHeader))
+	defer SetTenantResolver(nil)
+
+	c = newTestContext("/ping", http.Header{"Authorization": []string{"Bearer tenant-a-token"}, TenantHeader: []string{"tenant-a"}})
+	a, err = CheckAuthorization(context.TODO(), c, ScopeApiRead)
+	assert.NoError(t, err)
+	assert.NotNil(t, a)
+	assert.Equal(t, "tenant-a", Tenant(c))
+
+	c = newTestContext("/ping", http.Header{"Authorization": []string{"Bearer tenant-a-token"}, TenantHeader: []string{"tenant-b"}})
+	a, err = CheckAuthorization(context.TODO(), c, ScopeApiRead)
+	assert.Error(t, err)
+	assert.Nil(t, a)
+
+	// requests without a tenant are rejected
+	c = newTestContext("/ping", http.Header{"Authorization": []string{"Bearer tenant-a-token"}})
+	a, err = CheckAuthorization(context.TODO(), c, ScopeApiRead)
+	assert.ErrorIs(t, err, ErrTenantMismatch)
+	assert.Nil(t, a)
+
+	a, err = LookupByTenantToken("", "tenant-a-token")
+	assert.ErrorIs(t, err, ErrTenantMismatch)
+	assert.Nil(t, a)
+
+	c = newTestContext("/ping", nil)
+	c.Set(AuthContextKey, &ds)
+	a, err = CheckAuthorization(context.TODO(), c, ScopeApiRead)
+	assert.ErrorIs(t, err, ErrTenantMismatch)
+	assert.Nil(t, a)
+}
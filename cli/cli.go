@@ -3,18 +3,34 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/config"
 )
 
 var (
 	// ErrInvalidNumArguments indicates that the number of arguments in a command is not valid
 	ErrInvalidNumArguments = errors.New("invalid number of arguments")
+	// ErrInvalidFlag indicates that the value of a flag is not valid
+	ErrInvalidFlag = errors.New("invalid flag")
+	// ErrInvalidArgument indicates that the value of an argument is not valid
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrCredentialsMismatch indicates that the local credentials differ from the ones registered with the API
+	ErrCredentialsMismatch = errors.New("credentials mismatch")
+	// ErrCompletionDisabled indicates that the app does not answer completion requests, see cli.App.EnableBashCompletion
+	ErrCompletionDisabled = errors.New("shell completion is not enabled")
+	// ErrClientTooOld indicates that the API service no longer supports the app's version
+	ErrClientTooOld = errors.New("unsupported client version")
+	// ErrUnknownCommand indicates that there is neither a command nor a plugin with the name provided
+	ErrUnknownCommand = errors.New("unknown command")
 )
 
-// NoOpCommand is just a placeholder
+// NoOpCommand is just a placeholder, it fails with ErrNotImplemented
 func NoOpCommand(c *cli.Context) error {
-	return cli.Exit(fmt.Sprintf("%s: command '%s' is not implemented", c.App.Name, c.Command.Name), 0)
+	return fmt.Errorf("%w: command '%s'", ErrNotImplemented, c.Command.Name)
 }
 
 func WithGlobalFlags() []cli.Flag {
@@ -24,8 +40,54 @@ func WithGlobalFlags() []cli.Flag {
 			Usage:   "configuration and secrets directory",
 			Aliases: []string{"c"},
 		},
+		&cli.StringFlag{
+			Name:  "profile",
+			Usage: "configuration profile to use",
+		},
+		&cli.StringFlag{
+			Name:  "endpoint",
+			Usage: "API endpoint, overrides the configured endpoint",
+		},
+		&cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "override a setting, e.g. --set credentials.token=... (repeatable)",
+		},
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "print details of errors, e.g. the API's response and request id",
+		},
 	}
-	return flags
+	return MergeFlags(flags, WithInputFlags(), WithOutputFlags())
+}
+
+// HandleGlobalFlags applies the flags from WithGlobalFlags. Use it in the app's Before function.
+// Values from flags take precedence over environment variables, the config file and defaults.
+func HandleGlobalFlags(c *cli.Context) error {
+	if path := c.String("config"); path != "" {
+		config.SetConfigLocation(path)
+	}
+	if profile := c.String("profile"); profile != "" {
+		if !config.HasProfile(profile) {
+			return fmt.Errorf("%w: '%s'", config.ErrProfileNotFound, profile)
+		}
+		config.SetProfile(profile)
+	}
+	if endpoint := c.String("endpoint"); endpoint != "" {
+		config.SetFlag(config.KeyEndpoint, endpoint)
+	}
+	for _, kv := range c.StringSlice("set") {
+		key, value, found := strings.Cut(kv, "=")
+		if !found || key == "" {
+			return fmt.Errorf("%w: --set %s", ErrInvalidFlag, kv)
+		}
+		if err := config.ValidateValue(key, value); err != nil {
+			return fmt.Errorf("%w: --set %s", err, kv)
+		}
+		config.SetFlag(key, value)
+	}
+	api.SetVersionCheck(CheckVersion(c))
+
+	return checkOutputFlags(c)
 }
 
 // MergeCommands merges all the arrays with CLI commands into one
@@ -5,6 +5,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/config"
 )
 
 func TestMergeCommands(t *testing.T) {
@@ -79,3 +81,26 @@ func TestMergeFlags(t *testing.T) {
 	assert.Equal(t, 2, len(flags3))
 
 }
+
+func TestHandleGlobalFlags(t *testing.T) {
+	app := &cli.App{
+		Flags:  WithGlobalFlags(),
+		Before: HandleGlobalFlags,
+		Action: func(c *cli.Context) error { return nil },
+	}
+	defer config.ResetFlags()
+
+	assert.NoError(t, app.Run([]string{"test", "--endpoint", "http://flag:8080", "--set", "user_agent=flag-agent"}))
+
+	s, ok := config.Lookup(config.KeyEndpoint)
+	assert.True(t, ok)
+	assert.Equal(t, "http://flag:8080", s.Value)
+	assert.Equal(t, config.SourceFlag, s.Source)
+	assert.Equal(t, "flag-agent", config.GetConfig().Settings().UserAgent)
+
+	assert.Error(t, app.Run([]string{"test", "--set", "no-value"}))
+	assert.ErrorIs(t, app.Run([]string{"test", "--set", "user_agnet=typo"}), config.ErrUnknownKey)
+	assert.ErrorIs(t, app.Run([]string{"test", "--set", "server.port=http"}), config.ErrInvalidValue)
+	assert.NoError(t, app.Run([]string{"test", "--set", "server.port=9090"}))
+	assert.Equal(t, "9090", config.Server().Port)
+}
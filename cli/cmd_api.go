@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/api"
+)
+
+const (
+	// reads the request body from stdin
+	stdinFile = "-"
+)
+
+var (
+	// methods accepted by the api command
+	apiMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+)
+
+func WithAPICommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "api",
+			Usage:     "send an authenticated request to the API service",
+			UsageText: "api [-f key=value]... [--input file] [-H 'name: value']... [-p key=value]... [--paginate] [--include] [method] path",
+			Description: "sends a request to a path of the configured endpoint, e.g. 'api /a/v1/version', with the stored credentials. " +
+				"The method defaults to GET. Fields are sent as a JSON object, or as query parameters with GET, HEAD and DELETE " +
+				"or if the body is provided with --input. The response is printed to stdout, its status and timing to stderr.",
+			Action: APICommand,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "field",
+					Aliases: []string{"f"},
+					Usage:   "add a field to the request, e.g. -f name=value (repeatable)",
+				},
+				&cli.StringFlag{
+					Name:  "input",
+					Usage: "read the request body from a file, '-' reads from stdin",
+				},
+				&cli.StringSliceFlag{
+					Name:    "header",
+					Aliases: []string{"H"},
+					Usage:   "add a header to the request, e.g. -H 'Accept: text/plain' (repeatable)",
+				},
+				&cli.StringSliceFlag{
+					Name:    "param",
+					Aliases: []string{"p"},
+					Usage:   "add a query parameter to the request, e.g. -p page=2 (repeatable)",
+				},
+				&cli.BoolFlag{
+					Name:  "paginate",
+					Usage: "follow the 'next' links of the Link header and combine all pages",
+				},
+				&cli.BoolFlag{
+					Name:    "include",
+					Aliases: []string{"i"},
+					Usage:   "print the status line and the response headers",
+				},
+			},
+		},
+	}
+}
+
+func APICommand(c *cli.Context) error {
+	method, path := http.MethodGet, ""
+	switch c.NArg() {
+	case 1:
+		path = c.Args().First()
+	case 2:
+		method, path = strings.ToUpper(c.Args().Get(0)), c.Args().Get(1)
+	default:
+		return ErrInvalidNumArguments
+	}
+	if !isAPIMethod(method) {
+		return fmt.Errorf("%w: method '%s'", ErrInvalidArgument, method)
+	}
+	if !strings.HasPrefix(path, "/") && !strings.Contains(path, "://") {
+		path = "/" + path
+	}
+
+	headers, err := parseHeaders(c.StringSlice("header"))
+	if err != nil {
+		return err
+	}
+	params, err := parsePairs("param", c.StringSlice("param"))
+	if err != nil {
+		return err
+	}
+	fields, err := parsePairs("field", c.StringSlice("field"))
+	if err != nil {
+		return err
+	}
+
+	// the request body, if any
+	var body []byte
+	if input := c.String("input"); input != "" {
+		if body, err = readInput(c, input); err != nil {
+			return err
+		}
+		for k, v := range fields {
+			params[k] = v
+		}
+	} else if len(fields) > 0 {
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+			for k, v := range fields {
+				params[k] = v
+			}
+		} else if body, err = json.Marshal(fields); err != nil {
+			return err
+		}
+	}
+
+	cl := api.NewClient(nil)
+	if cl == nil {
+		return fmt.Errorf("could not create client")
+	}
+
+	pages := make([][]byte, 0, 1)
+	uri := withParams(path, params)
+	for uri != "" {
+		resp, data, err := sendRequest(c, cl, method, uri, headers, body)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, data)
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			if err := outputPages(c, pages); err != nil {
+				return err
+			}
+			return api.NewError(resp, data)
+		}
+
+		uri = ""
+		if c.Bool("paginate") {
+			uri = nextLink(resp.Request.URL, resp.Header.Get("Link"))
+			method, body = http.MethodGet, nil // the links are complete
+		}
+	}
+
+	return outputPages(c, pages)
+}
+
+// sendRequest performs one request and reports its status and timing
+func sendRequest(c *cli.Context, cl *api.Client, method, uri string, headers http.Header, body []byte) (*http.Response, []byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := cl.NewRequest(method, uri, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	start := time.Now()
+	resp, err := cl.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(c.App.ErrWriter, "%s %s: %s in %s\n", method, req.URL.RequestURI(), resp.Status, time.Since(start).Round(time.Millisecond))
+
+	if c.Bool("include") {
+		fmt.Fprintf(c.App.Writer, "%s %s\n", resp.Proto, resp.Status)
+		keys := make([]string, 0, len(resp.Header))
+		for k := range resp.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, v := range resp.Header[k] {
+				fmt.Fprintf(c.App.Writer, "%s: %s\n", k, v)
+			}
+		}
+		fmt.Fprintln(c.App.Writer)
+	}
+
+	return resp, data, nil
+}
+
+// outputPages prints the responses. JSON is rendered with Output, lists from several pages are
+// combined into one list. Anything else is printed as is.
+func outputPages(c *cli.Context, pages [][]byte) error {
+	values := make([]interface{}, 0, len(pages))
+	for _, p := range pages {
+		if len(bytes.TrimSpace(p)) == 0 {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(p, &v); err != nil {
+			// not JSON, print everything as is
+			for _, p := range pages {
+				if _, err := c.App.Writer.Write(p); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	var result interface{} = values
+	if len(values) == 1 {
+		result = values[0]
+	} else {
+		combined := make([]interface{}, 0)
+		for _, v := range values {
+			list, ok := v.([]interface{})
+			if !ok {
+				combined = nil
+				break
+			}
+			combined = append(combined, list...)
+		}
+		if combined != nil {
+			result = combined
+		}
+	}
+
+	// the text format of a response is its JSON
+	if c.String("output") == OutputText && c.String("query") == "" {
+		return Render(c.App.Writer, result, OutputJSON, "", nil)
+	}
+	return Output(c, result)
+}
+
+// readInput reads the request body from a file or stdin
+func readInput(c *cli.Context, input string) ([]byte, error) {
+	if input == stdinFile {
+		return io.ReadAll(c.App.Reader)
+	}
+	return os.ReadFile(input)
+}
+
+// parseHeaders parses headers like 'Accept: text/plain'
+func parseHeaders(values []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, h := range values {
+		k, v, found := strings.Cut(h, ":")
+		if !found || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("%w: --header %s", ErrInvalidFlag, h)
+		}
+		headers.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return headers, nil
+}
+
+// parsePairs parses values like 'key=value'
+func parsePairs(flag string, values []string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, kv := range values {
+		k, v, found := strings.Cut(kv, "=")
+		if !found || k == "" {
+			return nil, fmt.Errorf("%w: --%s %s", ErrInvalidFlag, flag, kv)
+		}
+		pairs[k] = v
+	}
+	return pairs, nil
+}
+
+// withParams adds query parameters to a path
+func withParams(path string, params map[string]string) string {
+	if len(params) == 0 {
+		return path
+	}
+
+	q := make(url.Values)
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + q.Encode()
+}
+
+// nextLink returns the 'next' URL of a Link header, resolved against the URL of the request,
+// see RFC 8288. Links to other origins fail in Client.NewRequest, they never get the credentials.
+func nextLink(base *url.URL, header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, _ := strings.Cut(link, ";")
+		target = strings.TrimSpace(target)
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "rel") && strings.Contains(" "+strings.Trim(v, `"`)+" ", " next ") {
+				ref, err := url.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					return ""
+				}
+				return base.ResolveReference(ref).String()
+			}
+		}
+	}
+	return ""
+}
+
+func isAPIMethod(method string) bool {
+	for _, m := range apiMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
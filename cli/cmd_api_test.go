@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/config"
+)
+
+func newAPITestServer() *httptest.Server {
+	e := echo.New()
+	e.Any("/echo", func(c echo.Context) error {
+		body, _ := io.ReadAll(c.Request().Body)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"method":        c.Request().Method,
+			"query":         c.Request().URL.RawQuery,
+			"body":          string(body),
+			"authorization": c.Request().Header.Get("Authorization"),
+			"accept":        c.Request().Header.Get("Accept"),
+		})
+	})
+	e.GET("/items", func(c echo.Context) error {
+		page, _ := strconv.Atoi(c.QueryParam("page"))
+		if page < 2 {
+			c.Response().Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=2>; rel="last"`, page+1))
+		}
+		return c.JSON(http.StatusOK, []map[string]int{{"page": page}})
+	})
+	e.GET("/elsewhere", func(c echo.Context) error {
+		c.Response().Header().Set("Link", `<https://example.com/items?page=2>; rel="next"`)
+		return c.JSON(http.StatusOK, []map[string]int{{"page": 1}})
+	})
+	e.GET("/text", func(c echo.Context) error {
+		return c.String(http.StatusOK, "plain text")
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return api.ErrorResponse(c, http.StatusNotFound, api.ErrInvalidRoute, "")
+	})
+	return httptest.NewServer(e)
+}
+
+func TestAPICommand(t *testing.T) {
+	srv := newAPITestServer()
+	defer srv.Close()
+	defer config.ResetFlags()
+
+	var out, errOut bytes.Buffer
+	app := newTestApp(WithAPICommands())
+	app.Writer = &out
+	app.ErrWriter = &errOut
+
+	run := func(args ...string) (map[string]interface{}, error) {
+		out.Reset()
+		errOut.Reset()
+		base := []string{"test", "--config", t.TempDir(), "--endpoint", srv.URL, "--set", "credentials.token=token"}
+		err := app.Run(append(base, args...))
+		var resp map[string]interface{}
+		json.Unmarshal(out.Bytes(), &resp)
+		return resp, err
+	}
+
+	resp, err := run("api", "/echo")
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodGet, resp["method"])
+	assert.Equal(t, "Bearer token", resp["authorization"])
+	assert.Contains(t, errOut.String(), "GET /echo: 200 OK in ")
+
+	// fields are the JSON body ...
+	resp, err = run("api", "-f", "name=value", "-p", "page=2", "-H", "Accept: text/plain", "post", "echo")
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPost, resp["method"])
+	assert.JSONEq(t, `{"name":"value"}`, resp["body"].(string))
+	assert.Equal(t, "page=2", resp["query"])
+	assert.Equal(t, "text/plain", resp["accept"])
+
+	// ... or query parameters
+	resp, err = run("api", "-f", "name=value", "/echo")
+	assert.NoError(t, err)
+	assert.Equal(t, "name=value", resp["query"])
+
+	input := filepath.Join(t.TempDir(), "body.json")
+	assert.NoError(t, os.WriteFile(input, []byte(`{"from":"file"}`), 0600))
+	resp, err = run("api", "--input", input, "PUT", "/echo")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"from":"file"}`, resp["body"])
+
+	app.Reader = strings.NewReader("from stdin")
+	resp, err = run("api", "--input", "-", "PATCH", "/echo")
+	assert.NoError(t, err)
+	assert.Equal(t, "from stdin", resp["body"])
+
+	// output formats and queries apply to the response
+	_, err = run("--query", ".method", "api", "/echo")
+	assert.NoError(t, err)
+	assert.Equal(t, "GET\n", out.String())
+
+	_, err = run("api", "--include", "/text")
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "HTTP/1.1 200 OK\n")
+	assert.True(t, strings.HasSuffix(out.String(), "\n\nplain text"))
+
+	// errors are printed and returned
+	resp, err = run("api", "/missing")
+	assert.ErrorIs(t, err, api.ErrApiInvocationError)
+	assert.Equal(t, float64(http.StatusNotFound), resp["status"])
+
+	_, err = run("api", "FETCH", "/echo")
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	_, err = run("api", "-H", "no-colon", "/echo")
+	assert.ErrorIs(t, err, ErrInvalidFlag)
+	_, err = run("api")
+	assert.ErrorIs(t, err, ErrInvalidNumArguments)
+}
+
+func TestAPICommandPaginate(t *testing.T) {
+	srv := newAPITestServer()
+	defer srv.Close()
+	defer config.ResetFlags()
+
+	var out bytes.Buffer
+	app := newTestApp(WithAPICommands())
+	app.Writer = &out
+	app.ErrWriter = io.Discard
+
+	base := []string{"test", "--config", t.TempDir(), "--endpoint", srv.URL}
+
+	assert.NoError(t, app.Run(append(base, "api", "/items")))
+	assert.JSONEq(t, `[{"page":0}]`, out.String())
+
+	out.Reset()
+	assert.NoError(t, app.Run(append(base, "api", "--paginate", "/items")))
+	assert.JSONEq(t, `[{"page":0},{"page":1},{"page":2}]`, out.String())
+
+	out.Reset()
+	assert.NoError(t, app.Run(append(base, "-o", "table", "api", "--paginate", "/items")))
+	assert.Equal(t, "PAGE\n0\n1\n2\n", out.String())
+
+	// the credentials never leave the endpoint
+	assert.ErrorIs(t, app.Run(append(base, "api", "--paginate", "/elsewhere")), api.ErrForeignOrigin)
+	assert.ErrorIs(t, app.Run(append(base, "api", "https://example.com/items")), api.ErrForeignOrigin)
+}
+
+func TestNextLink(t *testing.T) {
+	base, _ := url.Parse("http://localhost:8080/items?page=1")
+	assert.Equal(t, "http://localhost:8080/items?page=2", nextLink(base, `</items?page=2>; rel="next", </items?page=9>; rel="last"`))
+	assert.Equal(t, "http://localhost:8080/items?page=3", nextLink(base, `<?page=3>; rel="next"`))
+	assert.Equal(t, "https://example.com/2", nextLink(base, `<https://example.com/1>; rel=prev, <https://example.com/2>; rel="next"`))
+	assert.Equal(t, "", nextLink(base, `</items?page=9>; rel="last"`))
+	assert.Equal(t, "", nextLink(base, ""))
+}
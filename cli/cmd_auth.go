@@ -1,8 +1,13 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
@@ -15,6 +20,30 @@ import (
 	"github.com/txsvc/apikit/config"
 )
 
+type (
+	// authResult is the result of 'auth init', 'auth login' and 'auth logout'
+	authResult struct {
+		Client     string `json:"client_id"`
+		Status     string `json:"status"`
+		Passphrase string `json:"passphrase,omitempty"` // only if a new one was created
+		Message    string `json:"message"`
+	}
+
+	// statusResult is the result of 'auth status'
+	statusResult struct {
+		Endpoint   string   `json:"endpoint"`
+		Profile    string   `json:"profile"`
+		Project    string   `json:"project"`
+		Client     string   `json:"client_id"`
+		Scopes     []string `json:"scopes"`
+		Status     string   `json:"status"`
+		Expires    string   `json:"expires"`
+		Verified   bool     `json:"verified"` // the API service accepted the credentials
+		Mismatches []string `json:"mismatches"`
+		Message    string   `json:"message"`
+	}
+)
+
 func WithAuthCommands() []*cli.Command {
 	return []*cli.Command{
 		{
@@ -25,17 +54,24 @@ func WithAuthCommands() []*cli.Command {
 				{
 					Name:        "init",
 					Usage:       "register with the API service",
-					UsageText:   "init email [passphrase]", // FIXME: better description
-					Description: "longform description",    // FIXME: better description
+					UsageText:   "init [email [passphrase]]",
+					Description: "registers the email with the API service, which sends a login token to it. Missing values are prompted for, or the passphrase is read from stdin if it is piped. Without a passphrase a new one is created.",
 					Action:      InitCommand,
 				},
 				{
 					Name:        "login",
 					Usage:       "authenticate with the API service",
-					UsageText:   "login token",          // FIXME: better description
-					Description: "longform description", // FIXME: better description
+					UsageText:   "login [token]",
+					Description: "exchanges the token from the email for the credentials. The token is prompted for, or read from stdin if it is piped, if it is missing.",
 					Action:      LoginCommand,
 				},
+				{
+					Name:        "recover",
+					Usage:       "restore the credentials with the passphrase",
+					UsageText:   "recover [email]",
+					Description: "proves the knowledge of the passphrase from 'auth init' to the API service and stores new credentials, e.g. on a new machine. No email is sent. The passphrase is prompted for, or read from stdin if it is piped.",
+					Action:      RecoverCommand,
+				},
 				{
 					Name:        "logout",
 					Usage:       "logout from the API service",
@@ -43,24 +79,27 @@ func WithAuthCommands() []*cli.Command {
 					Description: "longform description", // FIXME: better description
 					Action:      LogoutCommand,
 				},
+				{
+					Name:        "status",
+					Aliases:     []string{"whoami"},
+					Usage:       "show the credentials in use and verify them with the API service",
+					UsageText:   "status",
+					Description: "Shows the client id, project, scopes and expiry of the active profile and reports any differences to what the API service has registered.",
+					Action:      StatusCommand,
+				},
 			},
 		},
 	}
 }
 
 func InitCommand(c *cli.Context) error {
-	if c.NArg() < 1 || c.NArg() > 2 {
+	if c.NArg() > 2 {
 		return ErrInvalidNumArguments
 	}
 
-	userid := ""
-	phrase := ""
-
-	if c.NArg() == 1 {
-		userid = c.Args().First() // path only
-	} else if c.NArg() == 2 {
-		userid = c.Args().First() // path and pass phrase
-		phrase = c.Args().Get(1)
+	userid, phrase, err := initArguments(c)
+	if err != nil {
+		return err
 	}
 
 	// create or validate the words
@@ -85,11 +124,10 @@ func InitCommand(c *cli.Context) error {
 	_apiKey := stdlib.Fingerprint(fmt.Sprintf("%s%s%s", config.GetConfig().Info().Name(), userid, mnemonic))
 
 	switch cfg.Credentials.Status {
-	case -1:
-		// set to INVALID
+	case settings.StateInvalid:
 		return config.ErrInvalidConfiguration
-	case 1:
-		if _apiKey == cfg.GetOption("APIKey") {
+	case settings.StateAuthorized:
+		if _apiKey == cfg.GetOption(config.OptionAPIKey) {
 			// correct pass phrase was provided, reset the authentication
 			if err := cl.LogoutCommand(); err != nil {
 				return err // FIXME: better err or just pass on what comes?
@@ -100,7 +138,7 @@ func InitCommand(c *cli.Context) error {
 		}
 	}
 
-	// 0, -2: don't care, can be overwritten as the client is not authorized yet
+	// init, logged out: don't care, can be overwritten as the client is not authorized yet
 
 	cfg.Credentials = &settings.Credentials{
 		ProjectID: config.GetConfig().Info().Name(),
@@ -109,72 +147,270 @@ func InitCommand(c *cli.Context) error {
 		Expires:   0, // FIXME: should this expire after some time?
 	}
 	cfg.Credentials.Status = settings.StateInit
-	cfg.SetOption("APIKey", _apiKey)
-	cfg.Scopes = make([]string, 0)
-	cfg.DefaultScopes = make([]string, 0)
-	cfg.Options = make(map[string]string)
+	cfg.SetOption(config.OptionAPIKey, _apiKey)
+	cfg.Scopes = make([]string, 0) // scopes are granted by the API on login, keep everything else
+
+	// the API keeps the verifier of the passphrase for 'auth recover', it is not stored locally
+	key, err := auth.RecoveryKey(cfg.Credentials.ProjectID, userid, mnemonic)
+	if err != nil {
+		return err
+	}
+	req := cfg.Clone()
+	req.SetOption(auth.OptionRecoveryKey, auth.RecoveryVerifier(key))
 
 	// now start the auth init process with the API
 
-	err = cl.InitCommand(cfg)
+	err = cl.InitCommand(&req)
 	if err != nil {
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+			return fmt.Errorf("%w: %w", auth.ErrAlreadyInitialized, err)
+		}
 		return err // FIXME: better err or just pass on what comes?
 	}
 
 	// finally save the file
-	pathToFile := filepath.Join(config.GetConfig().ConfigLocation(), config.DefaultConfigName)
-	if err := helpers.WriteDialSettings(cfg, pathToFile); err != nil {
-		return config.ErrInitializingConfiguration
+	if err := config.SaveSettings(cfg); err != nil {
+		return fmt.Errorf("%w: %v", config.ErrInitializingConfiguration, err)
 	}
 
+	result := authResult{
+		Client:  cfg.Credentials.ClientID,
+		Status:  stateName(cfg.Credentials.Status),
+		Message: "auth init done, check your email for the login token",
+	}
 	if phrase == "" {
-		fmt.Printf("userid: %s\n", cfg.Credentials.ClientID)
-		fmt.Printf("passphrase: \"%s\"\n\n", mnemonic)
-		fmt.Println("Make a copy of the passphrase and keep it secure !")
+		result.Passphrase = mnemonic
+	}
+	if err := Output(c, &result); err != nil {
+		return err
+	}
+	if !IsInteractive(c) {
+		return nil
 	}
 
-	return nil
+	// wait for the token from the email and login right away
+	token, err := prompt(c, "Token from the email (empty to login later)", true, nil)
+	if err != nil || token == "" {
+		return err
+	}
+	r, err := login(token)
+	if err != nil {
+		return err
+	}
+	return Output(c, r)
 }
 
 func LoginCommand(c *cli.Context) error {
-	if c.NArg() < 1 || c.NArg() > 1 {
+	if c.NArg() > 1 {
 		return ErrInvalidNumArguments
 	}
 
 	token := c.Args().First()
+	if token == "" {
+		var err error
+		if token, err = readToken(c); err != nil {
+			return err
+		}
+	}
+
+	result, err := login(token)
+	if err != nil {
+		return err
+	}
+	return Output(c, result)
+}
+
+// initArguments returns the email and the passphrase from the arguments, stdin or prompts
+func initArguments(c *cli.Context) (string, string, error) {
+	userid, phrase := c.Args().Get(0), c.Args().Get(1)
+	if phrase != "" {
+		fmt.Fprintln(c.App.ErrWriter, "warning: the passphrase is visible in the shell history, omit it to be prompted or pipe it to stdin")
+		return userid, phrase, nil
+	}
+
+	var err error
+	if userid == "" {
+		if !IsInteractive(c) {
+			return "", "", ErrInvalidNumArguments
+		}
+		if userid, err = prompt(c, "Email", false, validateEmail); err != nil {
+			return "", "", err
+		}
+	}
+
+	switch {
+	case IsInteractive(c):
+		phrase, err = prompt(c, "Passphrase (empty to create a new one)", true, validatePassphrase)
+		if err != nil || phrase == "" {
+			return userid, "", err
+		}
+		_, err = prompt(c, "Confirm the passphrase", true, func(s string) error {
+			if strings.Join(strings.Fields(s), " ") != strings.Join(strings.Fields(phrase), " ") {
+				return ErrPassphraseMismatch
+			}
+			return nil
+		})
+	case IsPiped(c):
+		phrase, err = readSecret(c)
+	}
+	return userid, phrase, err
+}
+
+// recoverArguments returns the email and the passphrase from the arguments, stdin or prompts
+func recoverArguments(c *cli.Context) (string, string, error) {
+	userid := c.Args().First()
+
+	var err error
+	if userid == "" {
+		if !IsInteractive(c) {
+			return "", "", ErrInvalidNumArguments
+		}
+		if userid, err = prompt(c, "Email", false, validateEmail); err != nil {
+			return "", "", err
+		}
+	}
+
+	phrase := ""
+	switch {
+	case IsInteractive(c):
+		phrase, err = prompt(c, "Passphrase", true, func(s string) error {
+			if err := validateNotEmpty(s); err != nil {
+				return err
+			}
+			return validatePassphrase(s)
+		})
+	case IsPiped(c):
+		phrase, err = readSecret(c)
+	}
+	if err == nil && phrase == "" {
+		return "", "", ErrInvalidNumArguments
+	}
+	return userid, phrase, err
+}
 
+// readToken reads the login token from stdin or asks for it
+func readToken(c *cli.Context) (string, error) {
+	switch {
+	case IsInteractive(c):
+		return prompt(c, "Token from the email", true, validateNotEmpty)
+	case IsPiped(c):
+		token, err := readSecret(c)
+		if err == nil && token == "" {
+			return "", ErrInvalidNumArguments
+		}
+		return token, err
+	}
+	return "", ErrInvalidNumArguments
+}
+
+// login exchanges the token from the email for the credentials and stores them
+func login(token string) (*authResult, error) {
 	// load settings
 	cfg := config.GetConfig().Settings()
 	if !cfg.Credentials.IsValid() {
-		return config.ErrInvalidConfiguration
+		return nil, config.ErrInvalidConfiguration
 	}
 
 	// now start the auth login process with the API
 	cl := api.NewClient(cfg)
 	if cl == nil {
-		return fmt.Errorf("could not create client")
+		return nil, fmt.Errorf("could not create client")
 	}
 
 	status, err := cl.LoginCommand(token)
 	if err != nil {
-		return err // FIXME: better err or just pass on what comes?
+		return nil, err // FIXME: better err or just pass on what comes?
 	}
 
 	// update the local config
 	cfg.Credentials.Token = status.Message
 	cfg.Credentials.Status = settings.StateAuthorized // LOGGED_IN
 	if !cfg.Credentials.IsValid() {
+		return nil, config.ErrInvalidConfiguration
+	}
+
+	if err := config.SaveSettings(cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", config.ErrInitializingConfiguration, err)
+	}
+
+	return &authResult{
+		Client:  cfg.Credentials.ClientID,
+		Status:  stateName(cfg.Credentials.Status),
+		Message: "auth login done",
+	}, nil
+}
+
+// validatePassphrase accepts "" or a valid mnemonic
+func validatePassphrase(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	_, err := helpers.CreateMnemonic(s)
+	return err
+}
+
+func RecoverCommand(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return ErrInvalidNumArguments
+	}
+
+	userid, phrase, err := recoverArguments(c)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := helpers.CreateMnemonic(phrase)
+	if err != nil {
+		return err
+	}
+
+	// load settings
+	cfg := config.GetConfig().Settings()
+	if cfg.Credentials.Status == settings.StateInvalid {
 		return config.ErrInvalidConfiguration
 	}
 
-	pathToFile := filepath.Join(config.GetConfig().ConfigLocation(), config.DefaultConfigName)
-	if err := helpers.WriteDialSettings(cfg, pathToFile); err != nil {
-		return config.ErrInitializingConfiguration
+	cfg.Credentials = &settings.Credentials{
+		ProjectID: config.GetConfig().Info().Name(),
+		ClientID:  userid,
+	}
+	key, err := auth.RecoveryKey(cfg.Credentials.ProjectID, userid, mnemonic)
+	if err != nil {
+		return err
+	}
+
+	// now start the auth recover process with the API
+	cl := api.NewClient(cfg)
+	if cl == nil {
+		return fmt.Errorf("could not create client")
+	}
+
+	status, err := cl.RecoverCommand(key)
+	if err != nil {
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+			return fmt.Errorf("%w: %w", auth.ErrRecoveryFailed, err)
+		}
+		return err
 	}
 
-	fmt.Println("auth login done") // FIXME: better message !
+	// same as after init and login, so that init with the passphrase works again
+	cfg.Credentials.Token = status.Message
+	cfg.Credentials.Status = settings.StateAuthorized
+	cfg.SetOption(config.OptionAPIKey, stdlib.Fingerprint(fmt.Sprintf("%s%s%s", cfg.Credentials.ProjectID, userid, mnemonic)))
+	if !cfg.Credentials.IsValid() {
+		return config.ErrInvalidConfiguration
+	}
 
-	return nil
+	if err := config.SaveSettings(cfg); err != nil {
+		return fmt.Errorf("%w: %v", config.ErrInitializingConfiguration, err)
+	}
+
+	return Output(c, &authResult{
+		Client:  cfg.Credentials.ClientID,
+		Status:  stateName(cfg.Credentials.Status),
+		Message: "auth recover done",
+	})
 }
 
 func LogoutCommand(c *cli.Context) error {
@@ -202,12 +438,172 @@ func LogoutCommand(c *cli.Context) error {
 	cfg.Credentials.Expires = stdlib.Now() - 1
 	cfg.Credentials.Status = settings.StateUndefined // LOGGED_OUT
 
-	pathToFile := filepath.Join(config.GetConfig().ConfigLocation(), config.DefaultConfigName)
-	if err := helpers.WriteDialSettings(cfg, pathToFile); err != nil {
-		return config.ErrInitializingConfiguration
+	if err := config.SaveSettings(cfg); err != nil {
+		return fmt.Errorf("%w: %v", config.ErrInitializingConfiguration, err)
 	}
 
-	fmt.Println("auth logout done") // FIXME: better message !
+	return Output(c, &authResult{
+		Client:  cfg.Credentials.ClientID,
+		Status:  stateName(cfg.Credentials.Status),
+		Message: "auth logout done",
+	})
+}
+
+func StatusCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	// load settings
+	cfg := config.GetConfig().Settings()
+	if cfg.Credentials == nil || cfg.Credentials.ClientID == "" {
+		if err := Output(c, &statusResult{Message: "not logged in"}); err != nil {
+			return err
+		}
+		return auth.ErrNotAuthorized
+	}
+
+	profile, err := config.CurrentProfile()
+	if err != nil {
+		return err
+	}
+
+	result := statusResult{
+		Endpoint:   cfg.Endpoint,
+		Profile:    profile,
+		Project:    cfg.Credentials.ProjectID,
+		Client:     cfg.Credentials.ClientID,
+		Scopes:     cfg.GetScopes(),
+		Status:     stateName(cfg.Credentials.Status),
+		Expires:    expiryString(cfg.Credentials.Expires),
+		Mismatches: []string{},
+	}
+
+	err = verifyCredentials(cfg, &result)
+	if oerr := Output(c, &result); oerr != nil {
+		return oerr
+	}
+	return err
+}
 
+// verifyCredentials asks the API what it knows about the credentials and compares it with the local settings
+func verifyCredentials(cfg *settings.DialSettings, result *statusResult) error {
+	if !cfg.Credentials.IsValid() {
+		result.Message = "the local credentials are not valid, use 'auth login' to authenticate"
+		return config.ErrInvalidConfiguration
+	}
+
+	cl := api.NewClient(cfg)
+	if cl == nil {
+		return fmt.Errorf("could not create client")
+	}
+	remote, err := cl.WhoAmI()
+	if err != nil {
+		result.Message = fmt.Sprintf("the API service rejected the credentials: %v", err)
+		return fmt.Errorf("%w: %w", auth.ErrNotAuthorized, err)
+	}
+	result.Verified = true
+
+	result.Mismatches = compareCredentials(cfg, remote)
+	if len(result.Mismatches) > 0 {
+		result.Message = "the local credentials differ from the API service"
+		return ErrCredentialsMismatch
+	}
+
+	result.Message = "the API service accepted the credentials"
 	return nil
 }
+
+// compareCredentials returns the differences between the local and the remote settings
+func compareCredentials(local, remote *settings.DialSettings) []string {
+	mismatches := make([]string, 0)
+	if remote.Credentials == nil {
+		remote.Credentials = &settings.Credentials{}
+	}
+
+	mismatch := func(name, l, r string) {
+		if l != r {
+			mismatches = append(mismatches, fmt.Sprintf("%s: local '%s', server '%s'", name, l, r))
+		}
+	}
+	mismatch("project", local.Credentials.ProjectID, remote.Credentials.ProjectID)
+	mismatch("client", local.Credentials.ClientID, remote.Credentials.ClientID)
+	mismatch("scopes", sortedScopes(local.GetScopes()), sortedScopes(remote.GetScopes()))
+	mismatch("status", stateName(local.Credentials.Status), stateName(remote.Credentials.Status))
+	mismatch("expires", expiryString(local.Credentials.Expires), expiryString(remote.Credentials.Expires))
+
+	return mismatches
+}
+
+func sortedScopes(scopes []string) string {
+	s := make([]string, len(scopes))
+	copy(s, scopes)
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func stateName(s settings.State) string {
+	switch s {
+	case settings.StateInit:
+		return "initialized"
+	case settings.StateInvalid:
+		return "invalid"
+	case settings.StateUndefined:
+		return "logged out"
+	case settings.StateAuthorized:
+		return "authorized"
+	}
+	return fmt.Sprintf("unknown (%d)", s)
+}
+
+func expiryString(expires int64) string {
+	if expires == 0 {
+		return "never"
+	}
+	if expires < 0 {
+		return "invalid"
+	}
+	t := time.Unix(expires, 0).UTC().Format(time.RFC3339)
+	if expires < stdlib.Now() {
+		return t + " (expired)"
+	}
+	return t
+}
+
+func (r *authResult) String() string {
+	if r.Passphrase == "" {
+		return r.Message + "\n"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "userid: %s\n", r.Client)
+	fmt.Fprintf(&sb, "passphrase: \"%s\"\n\n", r.Passphrase)
+	sb.WriteString("Make a copy of the passphrase and keep it secure !\n")
+	return sb.String()
+}
+
+func (r *statusResult) String() string {
+	if r.Client == "" {
+		return r.Message + "\n"
+	}
+
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "endpoint\t%s\n", r.Endpoint)
+	fmt.Fprintf(w, "profile\t%s\n", r.Profile)
+	fmt.Fprintf(w, "project\t%s\n", r.Project)
+	fmt.Fprintf(w, "client\t%s\n", r.Client)
+	fmt.Fprintf(w, "scopes\t%s\n", strings.Join(r.Scopes, ","))
+	fmt.Fprintf(w, "status\t%s\n", r.Status)
+	fmt.Fprintf(w, "expires\t%s\n", r.Expires)
+	w.Flush()
+
+	if r.Message != "" {
+		fmt.Fprintf(&sb, "\n%s\n", r.Message)
+	}
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(&sb, "  %s\n", m)
+	}
+	return sb.String()
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/auth"
+	"github.com/txsvc/apikit/config"
+)
+
+func TestStatusCommand(t *testing.T) {
+	dir := t.TempDir()
+	defer config.ResetFlags()
+
+	token := api.CreateSimpleToken()
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: "status",
+			ClientID:  "client",
+			Token:     token,
+			Status:    settings.StateAuthorized,
+		},
+		DefaultScopes: []string{auth.ScopeApiRead},
+	}
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	srv := httptest.NewServer(api.WithAuthEndpoints(echo.New()))
+	defer srv.Close()
+
+	app := newTestApp(WithAuthCommands())
+	args := []string{"test", "--config", dir, "--endpoint", srv.URL,
+		"--set", "credentials.project_id=status",
+		"--set", "credentials.client_id=client",
+		"--set", "credentials.token=" + token,
+		"--set", "credentials.status=5",
+		"--set", "scopes=" + auth.ScopeApiRead,
+	}
+
+	assert.NoError(t, app.Run(append(args, "auth", "status")))
+	assert.NoError(t, app.Run(append(args, "auth", "whoami")))
+
+	// the server granted different scopes
+	err := app.Run(append(args, "--set", "scopes=api:admin", "auth", "status"))
+	assert.ErrorIs(t, err, ErrCredentialsMismatch)
+
+	// the server does not know the token
+	err = app.Run(append(args, "--set", "credentials.token=unknown", "auth", "status"))
+	assert.ErrorIs(t, err, auth.ErrNotAuthorized)
+
+	assert.Error(t, app.Run(append(args, "auth", "status", "extra")))
+
+	// not logged in at all
+	config.ResetFlags()
+	assert.ErrorIs(t, app.Run([]string{"test", "--config", dir, "auth", "status"}), auth.ErrNotAuthorized)
+}
+
+func TestCompareCredentials(t *testing.T) {
+	local := &settings.DialSettings{
+		Credentials: &settings.Credentials{ProjectID: "p", ClientID: "c", Status: settings.StateAuthorized},
+		Scopes:      []string{"b", "a"},
+	}
+	remote := local.Clone()
+	remote.Scopes = []string{"a", "b"}
+	assert.Empty(t, compareCredentials(local, &remote))
+
+	remote.Credentials.Status = settings.StateUndefined
+	remote.Credentials.Expires = 1
+	assert.Len(t, compareCredentials(local, &remote), 2)
+}
+
+// newAuthServer returns a server that registers everyone with a recovery key and grants the
+// token 'granted' on login
+func newAuthServer(t *testing.T) *httptest.Server {
+	e := echo.New()
+	e.POST(api.NamespacePrefix+api.InitRoute, func(c echo.Context) error {
+		var ds settings.DialSettings
+		if err := c.Bind(&ds); err != nil {
+			return err
+		}
+		if err := auth.ValidRecoveryVerifier(ds.GetOption(auth.OptionRecoveryKey)); err != nil {
+			return api.ErrorResponse(c, http.StatusBadRequest, err, "")
+		}
+		return api.StandardResponse(c, http.StatusCreated, nil)
+	})
+	e.GET(api.NamespacePrefix+api.LoginRoute, func(c echo.Context) error {
+		return api.StandardResponse(c, http.StatusOK, api.NewStatus(http.StatusOK, "granted"))
+	})
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// withPrompts answers prompts in order and pretends that stdin is a terminal
+func withPrompts(t *testing.T, answers ...string) *[]string {
+	labels := make([]string, 0)
+	prompt = func(c *cli.Context, label string, mask bool, validate func(string) error) (string, error) {
+		labels = append(labels, label)
+		if len(answers) == 0 {
+			return "", io.EOF
+		}
+		answer := answers[0]
+		answers = answers[1:]
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				return "", err
+			}
+		}
+		return answer, nil
+	}
+	terminal = func(io.Reader) bool { return true }
+
+	t.Cleanup(func() {
+		prompt = promptTerminal
+		terminal = isTerminal
+	})
+	return &labels
+}
+
+func TestInitCommand(t *testing.T) {
+	config.SetSecretsLocation(t.TempDir())
+	defer config.SetSecretsLocation("")
+	defer config.ResetFlags()
+
+	srv := newAuthServer(t)
+
+	var out, errOut bytes.Buffer
+	app := newTestApp(WithAuthCommands())
+	app.Writer = &out
+	app.ErrWriter = &errOut
+
+	// the passphrase is piped, empty creates a new one
+	app.Reader = strings.NewReader("\n")
+	assert.NoError(t, app.Run([]string{"test", "--config", t.TempDir(), "--endpoint", srv.URL, "auth", "init", "me@example.com"}))
+	assert.Contains(t, out.String(), "passphrase: ")
+
+	// a passphrase on the command line works, but is discouraged
+	out.Reset()
+	phrase := "hard trend birth pioneer hero immune apology cook foam hurt tattoo artist"
+	assert.NoError(t, app.Run([]string{"test", "--config", t.TempDir(), "--endpoint", srv.URL, "auth", "init", "me@example.com", phrase}))
+	assert.NotContains(t, out.String(), "passphrase: ")
+	assert.Contains(t, errOut.String(), "warning: the passphrase is visible")
+
+	// nothing to prompt with
+	app.Reader = strings.NewReader("")
+	assert.ErrorIs(t, app.Run([]string{"test", "--config", t.TempDir(), "--endpoint", srv.URL, "auth", "init"}), ErrInvalidNumArguments)
+
+	// everything is prompted for, including the token
+	labels := withPrompts(t, "me@example.com", phrase, phrase, "token")
+	out.Reset()
+	assert.NoError(t, app.Run([]string{"test", "--config", t.TempDir(), "--endpoint", srv.URL, "auth", "init"}))
+	assert.Len(t, *labels, 4)
+	assert.Contains(t, out.String(), "auth login done")
+	assert.Equal(t, "granted", config.GetConfig().Settings().Credentials.Token)
+
+	// the confirmation does not match
+	withPrompts(t, "me@example.com", phrase, "hard trend birth")
+	assert.ErrorIs(t, app.Run([]string{"test", "--config", t.TempDir(), "--endpoint", srv.URL, "auth", "init"}), ErrPassphraseMismatch)
+
+	// --no-input never prompts
+	labels = withPrompts(t, "me@example.com")
+	assert.ErrorIs(t, app.Run([]string{"test", "--no-input", "--config", t.TempDir(), "--endpoint", srv.URL, "auth", "init"}), ErrInvalidNumArguments)
+	assert.Empty(t, *labels)
+}
+
+func TestLoginCommand(t *testing.T) {
+	config.SetSecretsLocation(t.TempDir())
+	defer config.SetSecretsLocation("")
+	defer config.ResetFlags()
+
+	srv := newAuthServer(t)
+	dir := t.TempDir()
+
+	app := newTestApp(WithAuthCommands())
+	app.Writer = io.Discard
+	app.ErrWriter = io.Discard
+	args := []string{"test", "--config", dir, "--endpoint", srv.URL}
+
+	app.Reader = strings.NewReader("")
+	assert.NoError(t, app.Run(append(args, "auth", "init", "me@example.com")))
+
+	// the token is piped
+	app.Reader = strings.NewReader("token\n")
+	assert.NoError(t, app.Run(append(args, "auth", "login")))
+	assert.Equal(t, "granted", config.GetConfig().Settings().Credentials.Token)
+
+	app.Reader = strings.NewReader("")
+	assert.ErrorIs(t, app.Run(append(args, "auth", "login")), ErrInvalidNumArguments)
+
+	// the token is prompted for
+	labels := withPrompts(t, "token")
+	assert.NoError(t, app.Run(append(args, "auth", "login")))
+	assert.Len(t, *labels, 1)
+
+	assert.ErrorIs(t, app.Run(append(args, "--no-input", "auth", "login")), ErrInvalidNumArguments)
+	assert.ErrorIs(t, app.Run(append(args, "auth", "login", "a", "b")), ErrInvalidNumArguments)
+}
+
+func TestRecoverCommand(t *testing.T) {
+	config.SetSecretsLocation(t.TempDir())
+	defer config.SetSecretsLocation("")
+	defer config.ResetFlags()
+
+	phrase := "hard trend birth pioneer hero immune apology cook foam hurt tattoo artist"
+	project := config.GetConfig().Info().Name()
+	key, err := auth.RecoveryKey(project, "recover@example.com", phrase)
+	assert.NoError(t, err)
+
+	ds := settings.DialSettings{
+		Credentials: &settings.Credentials{
+			ProjectID: project,
+			ClientID:  "recover@example.com",
+			Token:     api.CreateSimpleToken(),
+			Status:    settings.StateAuthorized,
+		},
+	}
+	ds.SetOption(auth.OptionRecoveryKey, auth.RecoveryVerifier(key))
+	assert.NoError(t, auth.UpdateStore(&ds))
+
+	srv := httptest.NewServer(api.WithAuthEndpoints(echo.New()))
+	defer srv.Close()
+
+	app := newTestApp(WithAuthCommands())
+	app.Writer = io.Discard
+	args := []string{"test", "--config", t.TempDir(), "--endpoint", srv.URL}
+
+	// the passphrase is piped
+	app.Reader = strings.NewReader(phrase + "\n")
+	assert.NoError(t, app.Run(append(args, "auth", "recover", "recover@example.com")))
+
+	cfg := config.GetConfig().Settings()
+	assert.Equal(t, "recover@example.com", cfg.Credentials.ClientID)
+	assert.Equal(t, settings.StateAuthorized, cfg.Credentials.Status)
+	assert.NotEqual(t, ds.Credentials.Token, cfg.Credentials.Token)
+	_, err = auth.LookupByToken(cfg.Credentials.Token)
+	assert.NoError(t, err)
+
+	// the passphrase is prompted for
+	labels := withPrompts(t, "recover@example.com", phrase)
+	assert.NoError(t, app.Run(append(args, "auth", "recover")))
+	assert.Len(t, *labels, 2)
+
+	// the wrong passphrase
+	withPrompts(t, "hard trend birth pioneer hero immune apology cook foam hurt tattoo tattoo")
+	err = app.Run(append(args, "auth", "recover", "recover@example.com"))
+	assert.ErrorIs(t, err, auth.ErrRecoveryFailed)
+	assert.Equal(t, ExitAuth, ExitCode(err))
+
+	assert.ErrorIs(t, app.Run(append(args, "--no-input", "auth", "recover", "recover@example.com")), ErrInvalidNumArguments)
+}
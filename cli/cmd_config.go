@@ -0,0 +1,540 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/config"
+)
+
+const (
+	// replaces secrets in the output of 'config list'
+	secretMask = "********"
+	// used if neither $VISUAL nor $EDITOR are set
+	defaultEditor = "vi"
+)
+
+type (
+	// settingResult is a setting and where it came from
+	settingResult struct {
+		Key    string `json:"key"`
+		Value  string `json:"value"`
+		Source string `json:"source"`
+	}
+
+	// settingResults is the result of 'config list'
+	settingResults []settingResult
+
+	// validationResult is the result of 'config validate'
+	validationResult struct {
+		Valid    bool            `json:"valid"`
+		Problems []problemResult `json:"problems"`
+	}
+
+	problemResult struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// migrationResult is the result of 'config migrate'
+	migrationResult struct {
+		DryRun bool     `json:"dry_run"`
+		Steps  []string `json:"steps"`
+	}
+
+	// conversionResult is the result of 'config convert'
+	conversionResult struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+
+	// profileResult is a profile in 'config profiles list'
+	profileResult struct {
+		Name   string `json:"name"`
+		Active bool   `json:"active"`
+	}
+
+	// profileResults is the result of 'config profiles list'
+	profileResults []profileResult
+)
+
+func WithConfigCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "config",
+			Usage: "options to inspect and change the configuration",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "get",
+					Usage:       "show the effective value of a setting",
+					UsageText:   "get key",
+					Description: "shows the value of a setting like 'endpoint', 'scopes' or 'options.APIKey', after applying the config file, APIKIT_* environment variables and flags",
+					Action:      GetCommand,
+				},
+				{
+					Name:        "set",
+					Usage:       "store a setting in the config file",
+					UsageText:   "set key value",
+					Description: "stores a setting in the config file of the active profile. Lists like 'scopes' are comma separated, server settings like 'server.port' are stored as well. The file is rewritten, comments in it are lost.",
+					Action:      SetCommand,
+				},
+				{
+					Name:        "unset",
+					Usage:       "remove a setting from the config file",
+					UsageText:   "unset key",
+					Description: "removes a setting from the config file of the active profile, it falls back to its default. The file is rewritten, comments in it are lost.",
+					Action:      UnsetCommand,
+				},
+				{
+					Name:      "list",
+					Usage:     "list all effective settings and where they come from",
+					UsageText: "list [--show-secrets]",
+					Action:    ListCommand,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "show-secrets",
+							Usage: "show tokens and secrets in clear text",
+						},
+					},
+				},
+				{
+					Name:        "edit",
+					Usage:       "edit the config file",
+					UsageText:   "edit",
+					Description: "opens the config file of the active profile in $VISUAL or $EDITOR. The changes are only saved if they are valid. Comments are not kept.",
+					Action:      EditCommand,
+				},
+				{
+					Name:        "validate",
+					Usage:       "check the effective settings",
+					UsageText:   "validate",
+					Description: "checks the endpoint, scopes, credentials and options and lists all problems found",
+					Action:      ValidateCommand,
+				},
+				{
+					Name:        "migrate",
+					Usage:       "move project-local files and migrate the config file to the current schema version",
+					UsageText:   "migrate [--dry-run]",
+					Description: "moves the config and secrets in './.config' and './.secrets' to the user's config directory, if they are in use, and migrates the config file of the active profile. The original file is kept as a backup without the credentials. Until then, older config files are migrated in memory whenever they are loaded.",
+					Action:      MigrateCommand,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "only show the migrations",
+						},
+					},
+				},
+				{
+					Name:        "convert",
+					Usage:       "convert the config file into another format",
+					UsageText:   "convert [--keep] [--force] json|yaml|toml",
+					Description: "converts the config file into JSON, YAML or TOML and removes the original file, unless --keep is set. Comments are not converted, use --keep to hold on to them. An existing file in the new format is only overwritten with --force.",
+					Action:      ConvertCommand,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "keep",
+							Usage: "keep the original file",
+						},
+						&cli.BoolFlag{
+							Name:  "force",
+							Usage: "overwrite an existing file in the new format",
+						},
+					},
+				},
+				{
+					Name:  "profiles",
+					Usage: "manage named configuration profiles",
+					Subcommands: []*cli.Command{
+						{
+							Name:      "list",
+							Usage:     "list all profiles, the active one is marked with '*'",
+							UsageText: "list",
+							Action:    ListProfilesCommand,
+						},
+						{
+							Name:      "use",
+							Usage:     "make a profile the active one",
+							UsageText: "use name",
+							Action:    UseProfileCommand,
+						},
+						{
+							Name:        "create",
+							Usage:       "create a new profile",
+							UsageText:   "create [--endpoint url] [--from profile] name",
+							Description: "creates a profile with the settings stored in the config file of the active profile, or of the one given with --from, without credentials. Environment variables and flags are not copied.",
+							Action:      CreateProfileCommand,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:  "endpoint",
+									Usage: "the profile's API endpoint",
+								},
+								&cli.StringFlag{
+									Name:  "from",
+									Usage: "copy the settings of another profile",
+								},
+							},
+						},
+						{
+							Name:      "delete",
+							Usage:     "delete a profile and its credentials",
+							UsageText: "delete name",
+							Action:    DeleteProfileCommand,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func GetCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	key := c.Args().First()
+	s, ok := config.Lookup(key)
+	if !ok && !config.IsFileKey(key) {
+		return fmt.Errorf("%w: '%s'", config.ErrUnknownKey, key)
+	}
+
+	return Output(c, &settingResult{Key: key, Value: s.Value, Source: s.Source.String()})
+}
+
+func SetCommand(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return ErrInvalidNumArguments
+	}
+
+	return config.SetValue(c.Args().Get(0), c.Args().Get(1))
+}
+
+func UnsetCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	return config.UnsetValue(c.Args().First())
+}
+
+func ListCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	result := make(settingResults, 0)
+	for _, s := range config.Resolved() {
+		value := s.Value
+		if config.IsSecretKey(s.Key) && value != "" && !c.Bool("show-secrets") {
+			value = secretMask
+		}
+		result = append(result, settingResult{Key: s.Key, Value: value, Source: s.Source.String()})
+	}
+
+	return Output(c, result)
+}
+
+func EditCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	path, err := config.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	// edit a copy, the config file is only replaced if the result is valid
+	values, err := config.ReadFileValues(path)
+	if os.IsNotExist(err) {
+		values, err = config.DefaultValues(), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp("", "config")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	edit := filepath.Join(tmp, filepath.Base(path))
+	if err := config.WriteFileValues(values, edit); err != nil {
+		return err
+	}
+
+	if err := runEditor(edit); err != nil {
+		return err
+	}
+
+	edited, err := config.ReadFileValues(edit)
+	if err != nil {
+		return fmt.Errorf("%w: %v", config.ErrInvalidConfiguration, err)
+	}
+	if err := config.ValidateValues(edited); err != nil {
+		return err
+	}
+
+	// the credentials are not in the config file, keep them
+	if cred := config.UnflattenDialSettings(edited).Credentials; *cred == (settings.Credentials{}) {
+		stored, err := config.StoredSettings()
+		if err != nil {
+			return err
+		}
+		for k, v := range config.FlattenDialSettings(&settings.DialSettings{Credentials: stored.Credentials}) {
+			edited[k] = v
+		}
+	}
+
+	return config.SaveValues(edited)
+}
+
+func ValidateCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	err := config.Validate()
+	if err == nil {
+		return Output(c, &validationResult{Valid: true, Problems: []problemResult{}})
+	}
+
+	var errs config.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	result := validationResult{Problems: make([]problemResult, len(errs))}
+	for i, e := range errs {
+		result.Problems[i] = problemResult{Field: e.Field, Message: e.Message}
+	}
+	if err := Output(c, &result); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %d problem(s) found", config.ErrInvalidConfiguration, len(errs))
+}
+
+func MigrateCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	// move project-local files first, the config file is migrated where it ends up
+	steps, err := config.MigrateLocations(c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+	schema, err := config.MigrateConfig(c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+	steps = append(steps, schema...)
+	if steps == nil {
+		steps = []string{}
+	}
+
+	return Output(c, &migrationResult{DryRun: c.Bool("dry-run"), Steps: steps})
+}
+
+func ConvertCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	ext := "." + strings.ToLower(c.Args().First())
+	format, err := config.FormatOf(ext)
+	if err != nil {
+		return err
+	}
+
+	from, err := config.ConfigFile()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	if f, _ := config.FormatOf(from); f == format {
+		return nil // nothing to do
+	}
+
+	to := filepath.Join(filepath.Dir(from), config.DefaultConfigName+ext)
+	if _, err := os.Stat(to); err == nil && !c.Bool("force") {
+		return fmt.Errorf("%w: '%s' already exists, use --force to overwrite it", ErrInvalidArgument, to)
+	}
+	if err := config.ConvertConfigFile(from, to); err != nil {
+		return err
+	}
+
+	// remove the original, otherwise it might still take precedence
+	if !c.Bool("keep") {
+		if err := os.Remove(from); err != nil {
+			return err
+		}
+	} else if config.ConfigFileIn(filepath.Dir(from)) != to {
+		fmt.Fprintf(c.App.ErrWriter, "warning: '%s' still takes precedence over '%s', remove it to use the converted file\n", from, to)
+	}
+
+	return Output(c, &conversionResult{From: from, To: to})
+}
+
+func ListProfilesCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	profiles, err := config.Profiles()
+	if err != nil {
+		return err
+	}
+
+	current, _ := config.CurrentProfile() // none is active if the selected one can't be used
+	result := make(profileResults, len(profiles))
+	for i, p := range profiles {
+		result[i] = profileResult{Name: p, Active: p == current}
+	}
+
+	return Output(c, result)
+}
+
+func UseProfileCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	return config.UseProfile(c.Args().First())
+}
+
+func CreateProfileCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	// start from the config file of the active profile or the one provided, never from the
+	// effective settings, i.e. without the values from the environment or flags
+	path, err := config.ConfigFile()
+	if err != nil {
+		return err
+	}
+	if from := c.String("from"); from != "" {
+		if !config.HasProfile(from) {
+			return fmt.Errorf("%w: '%s'", config.ErrProfileNotFound, from)
+		}
+		path = config.ConfigFileIn(config.ProfileLocation(from))
+	}
+	values, err := config.ReadFileValues(path)
+	if os.IsNotExist(err) {
+		values, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	ds := config.UnflattenDialSettings(values)
+
+	// never copy credentials from one profile to another
+	ds.Credentials = &settings.Credentials{}
+	delete(ds.Options, config.OptionAPIKey)
+
+	if endpoint := c.String("endpoint"); endpoint != "" {
+		ds.Endpoint = endpoint
+	}
+
+	return config.CreateProfile(c.Args().First(), ds)
+}
+
+func DeleteProfileCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	return config.DeleteProfile(c.Args().First())
+}
+
+// runEditor opens the file in the user's editor and waits until it is closed
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	// the editor can have arguments, e.g. 'code --wait'
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func (r *settingResult) String() string {
+	return r.Value + "\n"
+}
+
+func (r settingResults) String() string {
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, s := range r {
+		fmt.Fprintf(w, "%s\t%s\t(%s)\n", s.Key, s.Value, s.Source)
+	}
+	w.Flush()
+
+	return sb.String()
+}
+
+func (r *validationResult) String() string {
+	if r.Valid {
+		return "configuration is valid\n"
+	}
+
+	var sb strings.Builder
+	for _, p := range r.Problems {
+		fmt.Fprintf(&sb, "  %s: %s\n", p.Field, p.Message)
+	}
+	return sb.String()
+}
+
+func (r *migrationResult) String() string {
+	if len(r.Steps) == 0 {
+		return "config file is up to date\n"
+	}
+
+	var sb strings.Builder
+	for _, s := range r.Steps {
+		fmt.Fprintf(&sb, "  %s\n", s)
+	}
+	if r.DryRun {
+		sb.WriteString("dry-run, nothing was changed\n")
+	}
+	return sb.String()
+}
+
+func (r *conversionResult) String() string {
+	return fmt.Sprintf("converted '%s' to '%s'\n", r.From, r.To)
+}
+
+func (r profileResults) String() string {
+	var sb strings.Builder
+	for _, p := range r {
+		if p.Active {
+			fmt.Fprintf(&sb, "* %s\n", p.Name)
+		} else {
+			fmt.Fprintf(&sb, "  %s\n", p.Name)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/config"
+)
+
+func newTestApp(cmds ...[]*cli.Command) *cli.App {
+	return &cli.App{
+		Name:     "test",
+		Flags:    WithGlobalFlags(),
+		Before:   HandleGlobalFlags,
+		Commands: MergeCommands(cmds...),
+	}
+}
+
+func TestConvertCommand(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, config.WriteDialSettings(config.GetConfig().Settings(), filepath.Join(dir, config.DefaultConfigName)))
+
+	app := newTestApp(WithConfigCommands())
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "convert", "yaml"}))
+
+	_, err := os.Stat(filepath.Join(dir, "config.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, config.DefaultConfigName))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "config", "convert", "ini"}))
+
+	// a kept YAML file does not take precedence over JSON
+	var errOut bytes.Buffer
+	app.ErrWriter = &errOut
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "convert", "--keep", "json"}))
+	assert.Empty(t, errOut.String())
+
+	// but over TOML
+	assert.NoError(t, os.Remove(filepath.Join(dir, "config.json")))
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "convert", "--keep", "toml"}))
+	assert.Contains(t, errOut.String(), "still takes precedence")
+
+	// an existing file is only overwritten with --force
+	assert.ErrorIs(t, app.Run([]string{"test", "--config", dir, "config", "convert", "toml"}), ErrInvalidArgument)
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "convert", "--force", "toml"}))
+	_, err = os.Stat(filepath.Join(dir, "config.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestProfileCommands(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(WithConfigCommands())
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "profiles", "create", "--endpoint", "https://staging.example.com", "staging"}))
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "config", "profiles", "create", "staging"}))
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "profiles", "list"}))
+
+	// select the profile with the global flag
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "--profile", "unknown", "config", "profiles", "list"}))
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "--profile", "staging", "config", "profiles", "list"}))
+	assert.Equal(t, "https://staging.example.com", config.GetConfig().Settings().Endpoint)
+	config.SetProfile("")
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "profiles", "use", "staging"}))
+	profile, err := config.CurrentProfile()
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", profile)
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "profiles", "delete", "staging"}))
+	profile, err = config.CurrentProfile()
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultProfile, profile)
+
+	// environment overrides are not copied into a new profile
+	t.Setenv(config.EnvName(config.KeyUserAgent), "env/1.0")
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "profiles", "create", "dev"}))
+	values, err := config.ReadFileValues(config.ConfigFileIn(config.ProfileLocation("dev")))
+	assert.NoError(t, err)
+	assert.Empty(t, values[config.KeyUserAgent])
+}
+
+func TestSettingCommands(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(WithConfigCommands())
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "set", "user_agent", "test/1.0"}))
+	assert.Equal(t, "test/1.0", config.GetConfig().Settings().UserAgent)
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "get", "user_agent"}))
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "list"}))
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "unset", "user_agent"}))
+	assert.NotEqual(t, "test/1.0", config.GetConfig().Settings().UserAgent)
+
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "config", "get", "unknown"}))
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "config", "set", "endpoint", "not-a-url"}))
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "config", "set", "endpoint"}))
+}
+
+func TestEditCommand(t *testing.T) {
+	if _, err := exec.LookPath("sed"); err != nil {
+		t.Skip("sed is not available")
+	}
+
+	dir := t.TempDir()
+	app := newTestApp(WithConfigCommands())
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i -e s/localhost/example.com/")
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "edit"}))
+	assert.Equal(t, "http://example.com:8080", config.GetConfig().Settings().Endpoint)
+
+	// invalid changes are not saved
+	t.Setenv("EDITOR", "sed -i -e s/http:/ftp:/")
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "config", "edit"}))
+	assert.Equal(t, "http://example.com:8080", config.GetConfig().Settings().Endpoint)
+}
+
+func TestValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(WithConfigCommands())
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "validate"}))
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "set", "default_scopes", "api:unknown"}))
+	assert.ErrorIs(t, app.Run([]string{"test", "--config", dir, "config", "validate"}), config.ErrInvalidConfiguration)
+}
+
+func TestMigrateCommand(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(WithConfigCommands())
+
+	// a config file without a schema version
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, config.DefaultConfigName), []byte(`{"endpoint": "https://api.example.com"}`), 0600))
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "migrate", "--dry-run"}))
+	_, err := os.Stat(config.BackupFile(filepath.Join(dir, config.DefaultConfigName), 1))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "config", "migrate"}))
+	_, err = os.Stat(config.BackupFile(filepath.Join(dir, config.DefaultConfigName), 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com", config.GetConfig().Settings().Endpoint)
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Completion scripts for bash, zsh and PowerShell ask the app for suggestions with the
+// '--generate-bash-completion' flag, so the app has to set cli.App.EnableBashCompletion.
+// The fish script and the docs are generated from the command tree instead.
+
+const (
+	ShellBash       = "bash"
+	ShellZsh        = "zsh"
+	ShellFish       = "fish"
+	ShellPowerShell = "powershell"
+
+	DocsMan      = "man"
+	DocsMarkdown = "markdown"
+
+	// DefaultManSection is the section of user commands
+	DefaultManSection = 1
+
+	// replaced with the app's name in the completion scripts
+	progPlaceholder = "__PROG__"
+	// replaced with the app's name in function names
+	funcPlaceholder = "__FUNC__"
+)
+
+var (
+	// characters that can't be used in shell function names
+	funcNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	completionScripts = map[string]string{
+		ShellBash: `# bash completion for __PROG__, add this to ~/.bashrc:
+#   source <(__PROG__ completion bash)
+
+_cli_init_completion() {
+  COMPREPLY=()
+  _get_comp_words_by_ref "$@" cur prev words cword
+}
+
+___FUNC___bash_autocomplete() {
+  if [[ "${COMP_WORDS[0]}" != "source" ]]; then
+    local cur opts base words
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    if declare -F _init_completion >/dev/null 2>&1; then
+      _init_completion -n "=:" || return
+    else
+      _cli_init_completion -n "=:" || return
+    fi
+    words=("${words[@]:0:$cword}")
+    if [[ "$cur" == "-"* ]]; then
+      requestComp="${words[*]} ${cur} --generate-bash-completion"
+    else
+      requestComp="${words[*]} --generate-bash-completion"
+    fi
+    opts=$(eval "${requestComp}" 2>/dev/null)
+    COMPREPLY=($(compgen -W "${opts}" -- ${cur}))
+    return 0
+  fi
+}
+
+complete -o bashdefault -o default -o nospace -F ___FUNC___bash_autocomplete __PROG__
+`,
+		ShellZsh: `#compdef __PROG__
+# zsh completion for __PROG__, add this to ~/.zshrc:
+#   source <(__PROG__ completion zsh)
+
+___FUNC___zsh_autocomplete() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef ___FUNC___zsh_autocomplete __PROG__
+`,
+		ShellPowerShell: `# PowerShell completion for __PROG__, add this to your profile:
+#   __PROG__ completion powershell | Out-String | Invoke-Expression
+
+Register-ArgumentCompleter -Native -CommandName '__PROG__' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '') {
+        $words = $words[0..($words.Count - 2)]
+    }
+    $rest = @($words | Select-Object -Skip 1)
+    if ($wordToComplete -like '-*') {
+        $rest += $wordToComplete
+    }
+
+    & $words[0] @rest --generate-bash-completion 2>$null | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+	}
+)
+
+func WithCompletionCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:        "completion",
+			Usage:       "print the shell completion script",
+			UsageText:   "completion bash|zsh|fish|powershell",
+			Description: "prints a script that completes commands and flags, see the comment at the start of the script on how to install it",
+			Action:      CompletionCommand,
+		},
+	}
+}
+
+func WithDocsCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:        "docs",
+			Usage:       "generate the man page or the Markdown reference",
+			UsageText:   "docs [--format man|markdown] [--section n] [--dir path]",
+			Description: "generates the documentation of all commands and flags, printed to stdout or written to a file in --dir",
+			Action:      DocsCommand,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "man or markdown",
+					Value: DocsMan,
+				},
+				&cli.IntFlag{
+					Name:  "section",
+					Usage: "the section of the man page",
+					Value: DefaultManSection,
+				},
+				&cli.StringFlag{
+					Name:  "dir",
+					Usage: "write the documentation into a file in this directory",
+				},
+			},
+		},
+	}
+}
+
+func CompletionCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return ErrInvalidNumArguments
+	}
+
+	script, err := CompletionScript(c.App, strings.ToLower(c.Args().First()))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(c.App.Writer, script)
+	return err
+}
+
+// CompletionScript returns the completion script of an app for a shell.
+func CompletionScript(app *cli.App, shell string) (string, error) {
+	if shell == ShellFish {
+		return app.ToFishCompletion()
+	}
+
+	script, ok := completionScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported shell '%s'", ErrInvalidArgument, shell)
+	}
+	if !app.EnableBashCompletion {
+		return "", ErrCompletionDisabled
+	}
+
+	script = strings.ReplaceAll(script, funcPlaceholder, funcNameRegex.ReplaceAllString(app.Name, "_"))
+	return strings.ReplaceAll(script, progPlaceholder, app.Name), nil
+}
+
+func DocsCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	var doc, name string
+	var err error
+
+	switch format := c.String("format"); format {
+	case DocsMan:
+		section := c.Int("section")
+		if section < 1 || section > 9 {
+			return fmt.Errorf("%w: --section %d", ErrInvalidFlag, section)
+		}
+		doc, err = c.App.ToManWithSection(section)
+		name = fmt.Sprintf("%s.%d", c.App.Name, section)
+	case DocsMarkdown:
+		doc, err = c.App.ToMarkdown()
+		name = c.App.Name + ".md"
+	default:
+		return fmt.Errorf("%w: --format %s", ErrInvalidFlag, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := c.String("dir")
+	if dir == "" {
+		_, err = fmt.Fprint(c.App.Writer, doc)
+		return err
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.App.ErrWriter, "wrote %s\n", path)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionCommand(t *testing.T) {
+	var out bytes.Buffer
+	app := newTestApp(WithCompletionCommands(), WithConfigCommands())
+	app.Name = "my-cli"
+	app.Writer = &out
+
+	// bash, zsh and powershell need the app's help
+	assert.ErrorIs(t, app.Run([]string{"my-cli", "completion", "bash"}), ErrCompletionDisabled)
+
+	app.EnableBashCompletion = true
+	for _, shell := range []string{ShellBash, ShellZsh, ShellPowerShell} {
+		out.Reset()
+		assert.NoError(t, app.Run([]string{"my-cli", "completion", shell}), shell)
+		assert.Contains(t, out.String(), "my-cli", shell)
+		assert.NotContains(t, out.String(), progPlaceholder, shell)
+		assert.NotContains(t, out.String(), funcPlaceholder, shell)
+	}
+	assert.Contains(t, out.String(), "-CommandName 'my-cli'")
+
+	out.Reset()
+	assert.NoError(t, app.Run([]string{"my-cli", "completion", ShellBash}))
+	assert.Contains(t, out.String(), "complete -o bashdefault -o default -o nospace -F _my_cli_bash_autocomplete my-cli")
+
+	out.Reset()
+	assert.NoError(t, app.Run([]string{"my-cli", "completion", ShellFish}))
+	assert.Contains(t, out.String(), "complete -c my-cli")
+	assert.Contains(t, out.String(), "profiles")
+
+	assert.ErrorIs(t, app.Run([]string{"my-cli", "completion", "cmd"}), ErrInvalidArgument)
+	assert.ErrorIs(t, app.Run([]string{"my-cli", "completion"}), ErrInvalidNumArguments)
+}
+
+func TestDocsCommand(t *testing.T) {
+	var out bytes.Buffer
+	app := newTestApp(WithDocsCommands(), WithAuthCommands(), WithConfigCommands())
+	app.Writer = &out
+	app.ErrWriter = io.Discard
+
+	assert.NoError(t, app.Run([]string{"test", "docs", "--format", "markdown"}))
+	assert.Contains(t, out.String(), "# COMMANDS")
+	assert.Contains(t, out.String(), "### profiles")
+	assert.Contains(t, out.String(), "**--output, -o**")
+
+	out.Reset()
+	assert.NoError(t, app.Run([]string{"test", "docs"}))
+	assert.Contains(t, out.String(), ".TH test 1")
+
+	dir := t.TempDir()
+	assert.NoError(t, app.Run([]string{"test", "docs", "--section", "8", "--dir", dir}))
+	_, err := os.Stat(filepath.Join(dir, "test.8"))
+	assert.NoError(t, err)
+	assert.NoError(t, app.Run([]string{"test", "docs", "--format", "markdown", "--dir", dir}))
+	_, err = os.Stat(filepath.Join(dir, "test.md"))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, app.Run([]string{"test", "docs", "--format", "html"}), ErrInvalidFlag)
+	assert.ErrorIs(t, app.Run([]string{"test", "docs", "--section", "0"}), ErrInvalidFlag)
+}
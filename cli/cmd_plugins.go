@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/config"
+)
+
+// Plugins are executables named '<shortname>-<command>' in the plugins directory of the config
+// location or on the PATH. Each one acts as a command of the app, i.e. 'app foo a b' runs
+// 'app-foo a b'. The arguments are passed as they are, the resolved settings are passed in the
+// environment, see PluginEnv. Plugins are only looked up when they are needed, after the app's
+// Before function, so that --config and --profile apply. Unknown commands are resolved by the
+// app's Action:
+//
+//	cmds := kit.MergeCommands(myCmds, kit.WithAuthCommands(), kit.WithConfigCommands())
+//	app.Commands = kit.MergeCommands(cmds, kit.WithPluginCommands(cfg.Info().ShortName(), cmds))
+//	app.Action = kit.PluginAction(cfg.Info().ShortName(), app.Commands)
+
+const (
+	// PluginDirName is the directory of plugins in the config location
+	PluginDirName = "plugins"
+
+	PluginActive   = "active"
+	PluginShadowed = "shadowed" // a plugin with the same name was found first
+	PluginConflict = "conflict" // the app has a command with the same name
+
+)
+
+type (
+	// Plugin is an executable found by DiscoverPlugins
+	Plugin struct {
+		Name   string `json:"name"`
+		Path   string `json:"path"`
+		Status string `json:"status"`
+	}
+
+	// pluginResults is the result of 'plugins list'
+	pluginResults []*Plugin
+)
+
+// WithPluginCommands returns the commands to manage the plugins of the app, see PluginAction.
+func WithPluginCommands(shortName string, cmds []*cli.Command) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "plugins",
+			Usage: "options to manage plugins",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "list",
+					Usage:       "list the plugins",
+					UsageText:   "list",
+					Description: fmt.Sprintf("lists the executables named '%s-<command>' in the plugins directory of the config location and on the PATH, and if they are used", shortName),
+					Action: func(c *cli.Context) error {
+						if c.NArg() > 0 {
+							return ErrInvalidNumArguments
+						}
+						return Output(c, pluginResults(DiscoverPlugins(shortName, PluginDirs(), reservedNames(cmds))))
+					},
+				},
+			},
+		},
+	}
+}
+
+// PluginAction runs the plugin named by the first argument. Use it as the app's Action, it is
+// called for all arguments that are not a command of the app. Without arguments, the help is shown.
+func PluginAction(shortName string, cmds []*cli.Command) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowAppHelp(c)
+		}
+
+		name := c.Args().First()
+		for _, p := range DiscoverPlugins(shortName, PluginDirs(), reservedNames(cmds)) {
+			if p.Name == name && p.Status == PluginActive {
+				return RunPlugin(c, p, c.Args().Tail())
+			}
+		}
+		return fmt.Errorf("%w: '%s'", ErrUnknownCommand, name)
+	}
+}
+
+// reservedNames returns the names plugins can't use
+func reservedNames(cmds []*cli.Command) []string {
+	reserved := []string{"help", "h", "plugins"}
+	for _, cmd := range cmds {
+		reserved = append(reserved, cmd.Names()...)
+	}
+	return reserved
+}
+
+// PluginDirs returns the plugins directory of the config location and the directories on the PATH.
+// Relative directories on the PATH are skipped, they depend on the working directory.
+func PluginDirs() []string {
+	dirs := make([]string, 0)
+	if loc := config.GetConfig().ConfigLocation(); loc != "" {
+		dirs = append(dirs, filepath.Join(loc, PluginDirName))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// DiscoverPlugins finds the plugins of the app with the short name provided. The directories are
+// searched in order and the first plugin of a name is used. Plugins can't use reserved names.
+func DiscoverPlugins(shortName string, dirs, reserved []string) []*Plugin {
+	prefix := shortName + "-"
+	isReserved := make(map[string]bool)
+	for _, name := range reserved {
+		isReserved[name] = true
+	}
+	used := make(map[string]bool)
+
+	plugins := make([]*Plugin, 0)
+	visited := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" || visited[dir] {
+			continue
+		}
+		visited[dir] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // missing directories are fine
+		}
+		for _, e := range entries {
+			name, ok := pluginName(prefix, e.Name())
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if !isExecutable(path) {
+				continue
+			}
+
+			p := &Plugin{Name: name, Path: path, Status: PluginActive}
+			switch {
+			case isReserved[name]:
+				p.Status = PluginConflict
+			case used[name]:
+				p.Status = PluginShadowed
+			default:
+				used[name] = true
+			}
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins
+}
+
+// RunPlugin runs the plugin with the arguments provided and PluginEnv. The exit code of the
+// plugin is the exit code of the command.
+func RunPlugin(c *cli.Context, p *Plugin, args []string) error {
+	cmd := exec.Command(p.Path, args...)
+	cmd.Stdin = c.App.Reader
+	cmd.Stdout = c.App.Writer
+	cmd.Stderr = c.App.ErrWriter
+	cmd.Env = append(os.Environ(), PluginEnv()...)
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return cli.Exit("", exitErr.ExitCode())
+	}
+	return err
+}
+
+// PluginEnv returns the resolved settings as environment variables, the same ones the config
+// reads, so that plugins built with this package use the same endpoint, credentials and profile.
+func PluginEnv() []string {
+	ds := config.GetConfig().Settings()
+
+	env := []string{
+		config.EnvName(config.KeyEndpoint) + "=" + ds.Endpoint,
+	}
+	if profile, err := config.CurrentProfile(); err == nil {
+		env = append(env, config.ProfileENV+"="+profile)
+	}
+	if loc := config.GetConfig().ConfigLocation(); loc != "" {
+		env = append(env, config.ConfigDirLocationENV+"="+loc)
+	}
+	if ds.Credentials != nil {
+		env = append(env,
+			config.EnvName(config.KeyProjectID)+"="+ds.Credentials.ProjectID,
+			config.EnvName(config.KeyClientID)+"="+ds.Credentials.ClientID,
+			config.EnvName(config.KeyToken)+"="+ds.Credentials.Token,
+		)
+	}
+	return env
+}
+
+// pluginName returns the command name of an executable like '<shortname>-<command>'
+func pluginName(prefix, file string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(filepath.Ext(file), ".exe") {
+			return "", false
+		}
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+	if !strings.HasPrefix(file, prefix) || len(file) == len(prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(file, prefix), true
+}
+
+// isExecutable reports if path is a file that can be executed, following links
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || fi.Mode().Perm()&0111 != 0
+}
+
+func (r pluginResults) String() string {
+	if len(r) == 0 {
+		return "no plugins found\n"
+	}
+
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, p := range r {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Status, p.Path)
+	}
+	w.Flush()
+
+	return sb.String()
+}
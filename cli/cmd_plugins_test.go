@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/config"
+)
+
+// writePlugin creates a shell script in dir
+func writePlugin(t *testing.T, dir, name, script string) string {
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+func TestDiscoverPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	hello := writePlugin(t, first, "test-hello", "exit 0")
+	writePlugin(t, second, "test-hello", "exit 0")
+	writePlugin(t, second, "test-config", "exit 0")
+	writePlugin(t, second, "other-hello", "exit 0")
+	writePlugin(t, second, "test-", "exit 0")
+	assert.NoError(t, os.WriteFile(filepath.Join(second, "test-data"), []byte("not executable"), 0644))
+
+	plugins := DiscoverPlugins("test", []string{first, second, filepath.Join(first, "missing"), first}, []string{"config"})
+	if assert.Len(t, plugins, 3) {
+		assert.Equal(t, Plugin{Name: "hello", Path: hello, Status: PluginActive}, *plugins[0])
+		assert.Equal(t, PluginConflict, plugins[1].Status)
+		assert.Equal(t, "config", plugins[1].Name)
+		assert.Equal(t, PluginShadowed, plugins[2].Status)
+	}
+}
+
+func TestPluginCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	defer config.ResetFlags()
+
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	writePlugin(t, dir, "test-hello", `echo "$APIKIT_ENDPOINT $APIKIT_CREDENTIALS_TOKEN $*"`)
+	writePlugin(t, dir, "test-fail", "exit 3")
+	writePlugin(t, dir, "test-config", "exit 0")
+
+	var out bytes.Buffer
+	cmds := MergeCommands(WithConfigCommands())
+	app := newTestApp(cmds, WithPluginCommands("test", cmds))
+	app.Action = PluginAction("test", app.Commands)
+	app.Writer = &out
+	app.ErrWriter = io.Discard
+	app.ExitErrHandler = func(c *cli.Context, err error) {}
+
+	// flags after the command are passed to the plugin
+	assert.NoError(t, app.Run([]string{"test", "--endpoint", "http://localhost:1234", "--set", "credentials.token=secret", "hello", "--flag", "arg"}))
+	assert.Equal(t, "http://localhost:1234 secret --flag arg\n", out.String())
+
+	err := app.Run([]string{"test", "fail"})
+	assert.Equal(t, 3, ExitCode(err))
+
+	// the command wins over the plugin
+	out.Reset()
+	assert.NoError(t, app.Run([]string{"test", "plugins", "list"}))
+	assert.Contains(t, out.String(), "hello   active")
+	assert.Contains(t, out.String(), "config  conflict")
+	assert.Contains(t, out.String(), filepath.Join(dir, "test-fail"))
+
+	assert.ErrorIs(t, app.Run([]string{"test", "missing"}), ErrUnknownCommand)
+}
+
+func TestPluginDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	// the plugins directory of --config is used
+	loc, dir := t.TempDir(), t.TempDir()
+	t.Setenv("PATH", strings.Join([]string{"", "bin", dir}, string(filepath.ListSeparator)))
+	writePlugin(t, filepath.Join(loc, PluginDirName), "test-hello", "echo local")
+
+	var out bytes.Buffer
+	cmds := MergeCommands(WithConfigCommands())
+	app := newTestApp(cmds, WithPluginCommands("test", cmds))
+	app.Action = PluginAction("test", app.Commands)
+	app.Writer = &out
+
+	assert.NoError(t, app.Run([]string{"test", "--config", loc, "hello"}))
+	assert.Equal(t, "local\n", out.String())
+
+	// relative directories on the PATH are skipped
+	assert.Equal(t, []string{filepath.Join(loc, PluginDirName), dir}, PluginDirs())
+}
+
+func TestPluginEnv(t *testing.T) {
+	defer config.ResetFlags()
+	config.SetFlag(config.KeyEndpoint, "http://localhost:1234")
+	config.SetFlag(config.KeyClientID, "client")
+
+	env := PluginEnv()
+	assert.Contains(t, env, "APIKIT_ENDPOINT=http://localhost:1234")
+	assert.Contains(t, env, "APIKIT_CREDENTIALS_CLIENT_ID=client")
+	profile, err := config.CurrentProfile()
+	assert.NoError(t, err)
+	assert.Contains(t, env, config.ProfileENV+"="+profile)
+}
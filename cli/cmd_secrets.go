@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/cloudlib/helpers"
+
+	"github.com/txsvc/apikit/config"
+)
+
+type (
+	// rekeyResult is the result of 'secrets rekey'
+	rekeyResult struct {
+		Passphrase string `json:"passphrase,omitempty"` // only if a new mnemonic was created
+		KeyFile    bool   `json:"key_file"`             // the passphrase is stored in a key file
+		Message    string `json:"message"`
+	}
+)
+
+func WithSecretsCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "secrets",
+			Usage: "options to manage the encrypted credentials",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "rekey",
+					Usage:       "encrypt the credentials with a new passphrase",
+					UsageText:   "rekey [--mnemonic] [passphrase]",
+					Description: "re-encrypts the credentials of all profiles. Without a passphrase, a random one is stored in a key file. A passphrase or mnemonic has to be provided with " + config.SecretsPassphraseENV + " from then on.",
+					Action:      RekeyCommand,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "mnemonic",
+							Usage: "use a mnemonic as the passphrase, a new one is created if none is provided",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func RekeyCommand(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return ErrInvalidNumArguments
+	}
+
+	result := rekeyResult{}
+
+	phrase := c.Args().First()
+	if c.Bool("mnemonic") {
+		mnemonic, err := helpers.CreateMnemonic(phrase)
+		if err != nil {
+			return err
+		}
+		if phrase == "" {
+			result.Passphrase = mnemonic
+		}
+		phrase = mnemonic
+	}
+
+	if err := config.RekeySecrets(phrase); err != nil {
+		return err
+	}
+
+	if phrase != "" {
+		result.Message = fmt.Sprintf("secrets rekeyed, set %s to access the credentials", config.SecretsPassphraseENV)
+	} else {
+		result.KeyFile = true
+		result.Message = "secrets rekeyed"
+	}
+
+	return Output(c, &result)
+}
+
+func (r *rekeyResult) String() string {
+	if r.Passphrase == "" {
+		return r.Message + "\n"
+	}
+	return fmt.Sprintf("passphrase: \"%s\"\n\nMake a copy of the passphrase and keep it secure !\n%s\n", r.Passphrase, r.Message)
+}
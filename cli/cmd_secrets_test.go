@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/config"
+)
+
+func TestRekeyCommand(t *testing.T) {
+	dir := t.TempDir()
+	config.SetSecretsLocation(t.TempDir())
+	t.Setenv(config.SecretsPassphraseENV, "")
+	defer func() {
+		config.SetSecretsLocation("")
+		config.SetSecretsPassphrase("")
+	}()
+
+	app := newTestApp(WithSecretsCommands())
+	config.SetConfigLocation(dir)
+
+	cred := &settings.Credentials{ClientID: "client", Token: "token"}
+	assert.NoError(t, config.WriteCredentials(config.DefaultProfile, cred))
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "secrets", "rekey"}))
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "secrets", "rekey", "--mnemonic"}))
+	assert.Equal(t, "token", config.GetConfig().Settings().Credentials.Token)
+
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "secrets", "rekey", "--mnemonic", "not a valid mnemonic"}))
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "secrets", "rekey", "a", "b"}))
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/config"
+)
+
+// The API service reports its version and the oldest client version it supports with every
+// response. HandleGlobalFlags installs a check that warns once if the service is newer than
+// the app, and fails all requests if the app is older than the supported minimum.
+
+const (
+	VersionUpToDate    = "up to date"
+	VersionOutdated    = "outdated"    // the service is newer, the app still works
+	VersionUnsupported = "unsupported" // the app is older than the service supports
+)
+
+type (
+	// versionResult is the result of 'version'
+	versionResult struct {
+		Client *api.VersionResponse `json:"client"`
+		Server *api.VersionResponse `json:"server,omitempty"`
+		Status string               `json:"status,omitempty"`
+	}
+)
+
+func WithVersionCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:        "version",
+			Usage:       "show the version of the app and the API service",
+			UsageText:   "version [--server]",
+			Description: "shows the version and build of the app, and with --server the ones of the API service and if it supports the app",
+			Action:      VersionCommand,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "server",
+					Usage: "ask the API service for its version",
+				},
+			},
+		},
+	}
+}
+
+func VersionCommand(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return ErrInvalidNumArguments
+	}
+
+	client := api.NewVersionResponse(config.GetConfig().Info())
+	client.MinClientVersion = "" // only the service has one
+
+	result := versionResult{Client: client}
+	if !c.Bool("server") {
+		return Output(c, &result)
+	}
+
+	// an unsupported app can still ask for the version
+	cl := api.NewClient(nil, api.WithoutVersionCheck())
+	if cl == nil {
+		return fmt.Errorf("could not create client")
+	}
+	server, err := cl.Version()
+	if err != nil {
+		return err
+	}
+	result.Server = server
+	result.Status = versionStatus(client.Version, server.Version, server.MinClientVersion)
+
+	if err := Output(c, &result); err != nil {
+		return err
+	}
+	if result.Status == VersionUnsupported {
+		return fmt.Errorf("%w: %s is older than %s", ErrClientTooOld, client.Version, server.MinClientVersion)
+	}
+	return nil
+}
+
+// CheckVersion returns the api.VersionCheck used by the app, see HandleGlobalFlags. Warnings are
+// written to the app's ErrWriter, once. The check can be used by clients in several goroutines.
+func CheckVersion(c *cli.Context) api.VersionCheck {
+	version := config.GetConfig().Info().VersionString()
+	var warn sync.Once
+
+	return func(serverVersion, minClientVersion string) error {
+		switch versionStatus(version, serverVersion, minClientVersion) {
+		case VersionUnsupported:
+			return fmt.Errorf("%w: %s is older than %s", ErrClientTooOld, version, minClientVersion)
+		case VersionOutdated:
+			warn.Do(func() {
+				fmt.Fprintf(c.App.ErrWriter, "warning: %s %s is older than the API service %s, consider an upgrade\n", c.App.Name, version, serverVersion)
+			})
+		}
+		return nil
+	}
+}
+
+// versionStatus compares the app's version with the versions reported by the API service.
+// Versions that are unknown or can't be parsed are not compared, neither are development
+// builds of the app, see isDevelopmentBuild.
+func versionStatus(client, server, minClient string) string {
+	if isDevelopmentBuild(client) {
+		return VersionUpToDate
+	}
+	if minClient != "" {
+		if cmp, err := config.CompareVersions(client, minClient); err == nil && cmp < 0 {
+			return VersionUnsupported
+		}
+	}
+	if server != "" {
+		if cmp, err := config.CompareVersions(client, server); err == nil && cmp < 0 {
+			return VersionOutdated
+		}
+	}
+	return VersionUpToDate
+}
+
+// isDevelopmentBuild returns true if the version is not a release, see config.IsReleaseVersion,
+// or if the binary was built from a modified working tree, e.g. '1.2.3+a1b2c3d.dirty'.
+func isDevelopmentBuild(version string) bool {
+	if !config.IsReleaseVersion(version) {
+		return true
+	}
+	_, meta, _ := strings.Cut(version, "+")
+	for _, m := range strings.Split(meta, ".") {
+		if m == "dirty" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *versionResult) String() string {
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "client\t%s %s\n", r.Client.Name, r.Client.Version)
+	if r.Server != nil {
+		fmt.Fprintf(w, "server\t%s %s\n", r.Server.Name, r.Server.Version)
+		if r.Server.MinClientVersion != "" {
+			fmt.Fprintf(w, "minimum client\t%s\n", r.Server.MinClientVersion)
+		}
+		fmt.Fprintf(w, "status\t%s\n", r.Status)
+	}
+	w.Flush()
+
+	return sb.String()
+}
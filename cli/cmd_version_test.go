@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/config"
+)
+
+func TestVersionStatus(t *testing.T) {
+	assert.Equal(t, VersionUpToDate, versionStatus("1.2.3", "1.2.3", ""))
+	assert.Equal(t, VersionUpToDate, versionStatus("1.2.3", "1.2.0", "1.0.0"))
+	assert.Equal(t, VersionOutdated, versionStatus("1.2.3", "1.3.0", "1.0.0"))
+	assert.Equal(t, VersionUnsupported, versionStatus("1.2.3", "2.0.0", "1.5.0"))
+	assert.Equal(t, VersionUnsupported, versionStatus("1.2.3-rc.1", "1.2.3", "1.2.3"))
+
+	// unknown versions are not compared
+	assert.Equal(t, VersionUpToDate, versionStatus("1.2.3", "", ""))
+	assert.Equal(t, VersionUpToDate, versionStatus("(devel)", "1.2.3", "1.2.3"))
+	assert.Equal(t, VersionUpToDate, versionStatus("0.0.0-20261019160301-5fec11645c6f+5fec116", "1.2.3", "1.2.3"))
+
+	// neither are builds from a modified working tree
+	assert.Equal(t, VersionUpToDate, versionStatus("1.2.3+a1b2c3d.dirty", "2.0.0", "1.5.0"))
+	assert.Equal(t, VersionUnsupported, versionStatus("1.2.3+a1b2c3d", "2.0.0", "1.5.0"))
+}
+
+func TestVersionCommand(t *testing.T) {
+	defer config.ResetFlags()
+	defer api.SetMinClientVersion("")
+
+	srv := httptest.NewServer(api.WithVersionEndpoint(echo.New()))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	app := newTestApp(WithVersionCommands())
+	app.Writer = &out
+
+	version := config.GetConfig().Info().VersionString()
+	assert.NoError(t, app.Run([]string{"test", "version"}))
+	assert.Contains(t, out.String(), version)
+	assert.NotContains(t, out.String(), "server")
+
+	out.Reset()
+	assert.NoError(t, app.Run([]string{"test", "--endpoint", srv.URL, "--output", "json", "version", "--server"}))
+	var result versionResult
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, version, result.Client.Version)
+	if assert.NotNil(t, result.Server) {
+		assert.Equal(t, version, result.Server.Version)
+	}
+	assert.Equal(t, VersionUpToDate, result.Status)
+
+	// the service requires a newer app, the version can still be asked for
+	assert.NoError(t, api.SetMinClientVersion("999.0.0"))
+	out.Reset()
+	err := app.Run([]string{"test", "--endpoint", srv.URL, "version", "--server"})
+	assert.ErrorIs(t, err, ErrClientTooOld)
+	assert.Contains(t, out.String(), "minimum client  999.0.0")
+	assert.Contains(t, out.String(), VersionUnsupported)
+
+	// all other requests fail
+	app = newTestApp(WithAPICommands())
+	app.Writer = io.Discard
+	app.ErrWriter = io.Discard
+	err = app.Run([]string{"test", "--endpoint", srv.URL, "api", api.NamespacePrefix + api.VersionRoute})
+	assert.ErrorIs(t, err, ErrClientTooOld)
+}
+
+func TestCheckVersion(t *testing.T) {
+	var errOut bytes.Buffer
+	app := newTestApp()
+	app.ErrWriter = &errOut
+	app.Action = func(c *cli.Context) error {
+		check := CheckVersion(c)
+		assert.NoError(t, check("", ""))
+		assert.NoError(t, check("999.0.0", ""))
+		assert.NoError(t, check("999.0.0", ""))
+		assert.ErrorIs(t, check("999.0.0", "999.0.0"), ErrClientTooOld)
+		return nil
+	}
+	assert.NoError(t, app.Run([]string{"test"}))
+
+	// warned once
+	assert.Equal(t, 1, bytes.Count(errOut.Bytes(), []byte("warning:")))
+	assert.Contains(t, errOut.String(), "older than the API service 999.0.0")
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/auth"
+	"github.com/txsvc/apikit/config"
+)
+
+// Errors are mapped to exit codes so that scripts can tell what went wrong. Install ExitErrHandler
+// in the app to print errors with a hint and to exit with the matching code:
+//
+//	app := &cli.App{
+//		ExitErrHandler: kit.ExitErrHandler,
+//		...
+//	}
+//	if err := app.Run(os.Args); err != nil {
+//		os.Exit(kit.ExitCode(err)) // e.g. unknown flags
+//	}
+
+const (
+	ExitOK       = 0 // success
+	ExitError    = 1 // anything not covered below
+	ExitUsage    = 2 // invalid arguments or flags
+	ExitConfig   = 3 // invalid or unreadable configuration
+	ExitAuth     = 4 // not authenticated or not authorized
+	ExitNotFound = 5 // a profile, file or API resource does not exist
+	ExitNetwork  = 6 // the API service can't be reached
+	ExitServer   = 7 // the API service failed to process the request
+	ExitUpgrade  = 8 // the API service no longer supports the app's version
+)
+
+var (
+	// ErrNotImplemented indicates that a command is only a placeholder
+	ErrNotImplemented = errors.New("not implemented")
+
+	usageErrors = []error{
+		ErrInvalidNumArguments, ErrInvalidFlag, ErrInvalidArgument, ErrInvalidQuery, ErrPassphraseMismatch, ErrUnknownCommand,
+		config.ErrUnknownKey, config.ErrInvalidValue, config.ErrInvalidProfileName, config.ErrProfileExists,
+		api.ErrForeignOrigin,
+	}
+	configErrors = []error{
+		config.ErrInvalidConfiguration, config.ErrInitializingConfiguration, config.ErrMissingConfigurator,
+		config.ErrUnsupportedSchema, config.ErrReadOnlyConfiguration, ErrCompletionDisabled,
+	}
+	authErrors = []error{
+		auth.ErrNotAuthorized, auth.ErrAlreadyAuthorized, auth.ErrAlreadyInitialized, auth.ErrInvalidCredentials,
+		auth.ErrNoToken, auth.ErrTokenExpired, auth.ErrTokenNotFound, auth.ErrTenantMismatch,
+		auth.ErrRecoveryFailed, auth.ErrInvalidRecoveryKey, api.ErrMissingCredentials, config.ErrNoSecretsKey,
+		config.ErrDecryptingSecrets, ErrCredentialsMismatch,
+	}
+	notFoundErrors = []error{
+		config.ErrProfileNotFound, fs.ErrNotExist,
+	}
+
+	// messages printed from flag parsing errors
+	usagePrefixes = []string{"flag provided but not defined", "invalid value", "flag needs an argument"}
+)
+
+// ExitCode returns the exit code of an error, see ExitOK etc.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var ec cli.ExitCoder
+	if errors.As(err, &ec) {
+		return ec.ExitCode()
+	}
+	if errors.Is(err, ErrClientTooOld) {
+		return ExitUpgrade
+	}
+
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden:
+			return ExitAuth
+		case apiErr.Status == http.StatusNotFound:
+			return ExitNotFound
+		case apiErr.Status >= http.StatusInternalServerError:
+			return ExitServer
+		}
+	}
+
+	switch {
+	case isOneOf(err, usageErrors):
+		return ExitUsage
+	case isOneOf(err, authErrors):
+		return ExitAuth
+	case isOneOf(err, notFoundErrors):
+		return ExitNotFound
+	case isOneOf(err, configErrors):
+		return ExitConfig
+	case isNetworkError(err):
+		return ExitNetwork
+	case apiErr != nil:
+		return ExitServer // any other status the client did not expect
+	}
+
+	for _, p := range usagePrefixes {
+		if strings.HasPrefix(err.Error(), p) {
+			return ExitUsage
+		}
+	}
+	return ExitError
+}
+
+// Hint returns a suggestion how to fix the error, or "" if there is none. The app name is used
+// in the suggested commands.
+func Hint(name string, err error) string {
+	var apiErr *api.Error
+	isAPIErr := errors.As(err, &apiErr)
+
+	switch {
+	case errors.Is(err, ErrCredentialsMismatch):
+		return fmt.Sprintf("run '%s auth login' to renew the credentials", name)
+	case errors.Is(err, auth.ErrAlreadyAuthorized):
+		return fmt.Sprintf("run '%s auth logout' first, or provide the passphrase to initialize again", name)
+	case errors.Is(err, auth.ErrAlreadyInitialized):
+		return fmt.Sprintf("the email is registered, run '%s auth login' with the token from the email or '%s auth recover'", name, name)
+	case errors.Is(err, auth.ErrRecoveryFailed):
+		return "check the email and the passphrase, only clients that logged in before can recover"
+	case isAPIErr && apiErr.Status == http.StatusForbidden:
+		return fmt.Sprintf("the credentials lack the required scopes, run '%s auth status' to see them", name)
+	case isOneOf(err, []error{config.ErrNoSecretsKey, config.ErrDecryptingSecrets}):
+		return fmt.Sprintf("set %s to the passphrase of the credentials", config.SecretsPassphraseENV)
+	case errors.Is(err, config.ErrReadOnlyConfiguration):
+		return fmt.Sprintf("the configuration comes from the environment, set %s* variables instead", config.EnvPrefix)
+	case errors.Is(err, ErrClientTooOld):
+		return fmt.Sprintf("upgrade %s, run '%s version --server' to see the versions", name, name)
+	case errors.Is(err, ErrCompletionDisabled):
+		return "set EnableBashCompletion in the app"
+	case ExitCode(err) == ExitAuth:
+		return fmt.Sprintf("run '%s auth login' first", name)
+	case errors.Is(err, config.ErrProfileNotFound):
+		return fmt.Sprintf("run '%s config profiles list' to see all profiles", name)
+	case errors.Is(err, config.ErrUnknownKey):
+		return fmt.Sprintf("run '%s config list' to see all settings", name)
+	case errors.Is(err, config.ErrInvalidConfiguration):
+		return fmt.Sprintf("run '%s config validate' to see all problems", name)
+	case errors.Is(err, config.ErrUnsupportedSchema):
+		return fmt.Sprintf("the config file was written by a newer version of %s", name)
+	case ExitCode(err) == ExitUsage:
+		return "use --help to see the usage"
+	case ExitCode(err) == ExitNetwork:
+		return fmt.Sprintf("check the endpoint with '%s config get endpoint'", name)
+	case ExitCode(err) == ExitServer:
+		return "the API service failed, try again later"
+	}
+	return ""
+}
+
+// PrintError writes the error and a hint. With verbose, the chain of wrapped errors and details
+// of API errors like the status and the request id are written as well.
+func PrintError(w io.Writer, name string, err error, verbose bool) {
+	fmt.Fprintf(w, "error: %s\n", err)
+	if hint := Hint(name, err); hint != "" {
+		fmt.Fprintf(w, "hint: %s\n", hint)
+	}
+	if !verbose {
+		return
+	}
+
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		fmt.Fprintf(w, "  status: %d %s\n", apiErr.Status, http.StatusText(apiErr.Status))
+		fmt.Fprintf(w, "  message: %s\n", apiErr.Message)
+		if apiErr.RequestID != "" {
+			fmt.Fprintf(w, "  request id: %s\n", apiErr.RequestID)
+		}
+	}
+	for _, e := range unwrapAll(err) {
+		fmt.Fprintf(w, "  caused by: %s (%T)\n", e, e)
+	}
+	fmt.Fprintf(w, "  exit code: %d\n", ExitCode(err))
+}
+
+// ExitErrHandler prints errors with PrintError and exits with ExitCode. Use it as cli.App.ExitErrHandler.
+func ExitErrHandler(c *cli.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	// messages of cli.Exit are printed as they are
+	var ec cli.ExitCoder
+	if errors.As(err, &ec) {
+		cli.HandleExitCoder(err)
+		return
+	}
+
+	PrintError(c.App.ErrWriter, c.App.Name, err, c.Bool("verbose"))
+	cli.OsExiter(ExitCode(err))
+}
+
+// unwrapAll returns all errors wrapped by err, depth first
+func unwrapAll(err error) []error {
+	var all []error
+
+	var walk func(error)
+	walk = func(e error) {
+		switch u := e.(type) {
+		case interface{ Unwrap() error }:
+			if next := u.Unwrap(); next != nil {
+				all = append(all, next)
+				walk(next)
+			}
+		case interface{ Unwrap() []error }:
+			for _, next := range u.Unwrap() {
+				all = append(all, next)
+				walk(next)
+			}
+		}
+	}
+	walk(err)
+
+	return all
+}
+
+func isOneOf(err error, targets []error) bool {
+	for _, t := range targets {
+		if errors.Is(err, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &netErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/txsvc/apikit/api"
+	"github.com/txsvc/apikit/auth"
+	"github.com/txsvc/apikit/config"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitOK},
+		{fmt.Errorf("something"), ExitError},
+		{cli.Exit("bye", 42), 42},
+		{ErrInvalidNumArguments, ExitUsage},
+		{fmt.Errorf("%w: --output xml", ErrInvalidFlag), ExitUsage},
+		{fmt.Errorf("%w: 'foo'", config.ErrUnknownKey), ExitUsage},
+		{fmt.Errorf("flag provided but not defined: -x"), ExitUsage},
+		{config.ErrInvalidConfiguration, ExitConfig},
+		{config.ErrReadOnlyConfiguration, ExitConfig},
+		{auth.ErrNotAuthorized, ExitAuth},
+		{fmt.Errorf("%w: %w", auth.ErrNotAuthorized, &api.Error{Status: http.StatusUnauthorized}), ExitAuth},
+		{ErrCredentialsMismatch, ExitAuth},
+		{&api.Error{Status: http.StatusForbidden}, ExitAuth},
+		{config.ErrProfileNotFound, ExitNotFound},
+		{&fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}, ExitNotFound},
+		{&api.Error{Status: http.StatusNotFound}, ExitNotFound},
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, ExitNetwork},
+		{&api.Error{Status: http.StatusBadGateway}, ExitServer},
+		{&api.Error{Status: http.StatusTeapot}, ExitServer},
+		{fmt.Errorf("%w: 1.0.0 is older than 1.2.0", ErrClientTooOld), ExitUpgrade},
+		{fmt.Errorf("%w: command 'x'", ErrNotImplemented), ExitError},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, ExitCode(tt.err), fmt.Sprintf("%v", tt.err))
+	}
+}
+
+func TestHint(t *testing.T) {
+	assert.Equal(t, "run 'my-cli auth login' first", Hint("my-cli", auth.ErrNotAuthorized))
+	assert.Equal(t, "run 'my-cli auth login' to renew the credentials", Hint("my-cli", ErrCredentialsMismatch))
+	assert.Contains(t, Hint("my-cli", &api.Error{Status: http.StatusForbidden}), "'my-cli auth status'")
+	assert.Contains(t, Hint("my-cli", config.ErrProfileNotFound), "'my-cli config profiles list'")
+	assert.Contains(t, Hint("my-cli", config.ErrReadOnlyConfiguration), config.EnvPrefix)
+	assert.Equal(t, "use --help to see the usage", Hint("my-cli", ErrInvalidNumArguments))
+	assert.Empty(t, Hint("my-cli", fmt.Errorf("something")))
+}
+
+func TestPrintError(t *testing.T) {
+	err := fmt.Errorf("%w: %w", auth.ErrNotAuthorized, &api.Error{Status: http.StatusUnauthorized, Message: "token expired", RequestID: "req-1"})
+
+	var out bytes.Buffer
+	PrintError(&out, "my-cli", err, false)
+	assert.Equal(t, "error: not authorized: token expired\nhint: run 'my-cli auth login' first\n", out.String())
+
+	out.Reset()
+	PrintError(&out, "my-cli", err, true)
+	assert.Contains(t, out.String(), "  status: 401 Unauthorized\n")
+	assert.Contains(t, out.String(), "  request id: req-1\n")
+	assert.Contains(t, out.String(), "  caused by: not authorized (*errors.errorString)\n")
+	assert.Contains(t, out.String(), "  caused by: api invocation error")
+	assert.Contains(t, out.String(), "  exit code: 4\n")
+}
+
+func TestExitErrHandler(t *testing.T) {
+	code := -1
+	exiter := cli.OsExiter
+	cli.OsExiter = func(c int) { code = c }
+	defer func() { cli.OsExiter = exiter }()
+
+	var out bytes.Buffer
+	app := newTestApp(WithConfigCommands())
+	app.Writer = io.Discard
+	app.ErrWriter = &out
+	app.ExitErrHandler = ExitErrHandler
+
+	app.Run([]string{"test", "config", "get", "nothing"})
+	assert.Equal(t, ExitUsage, code)
+	assert.Contains(t, out.String(), "error: unknown setting: 'nothing'")
+	assert.Contains(t, out.String(), "hint: run 'test config list' to see all settings")
+	assert.NotContains(t, out.String(), "exit code")
+
+	out.Reset()
+	app.Run([]string{"test", "--verbose", "config", "get", "nothing"})
+	assert.Contains(t, out.String(), "  exit code: 2")
+
+	code = -1
+	app.Run([]string{"test", "config", "get", "endpoint"})
+	assert.Equal(t, -1, code)
+}
+
+func TestNoOpCommand(t *testing.T) {
+	app := newTestApp([]*cli.Command{{Name: "noop", Action: NoOpCommand}})
+	err := app.Run([]string{"test", "noop"})
+	assert.ErrorIs(t, err, ErrNotImplemented)
+	assert.Equal(t, ExitError, ExitCode(err))
+}
@@ -0,0 +1,417 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Results of commands are rendered in the format selected with --output. The text format is meant
+// for humans and uses the result's String() method if it has one, all other formats can be parsed
+// by scripts. The field names are the result's JSON names, for JSON, YAML, tables and --query.
+
+const (
+	OutputText  = "text"  // human-readable, the default
+	OutputJSON  = "json"  // indented JSON
+	OutputYAML  = "yaml"  // YAML
+	OutputTable = "table" // one row per item, see --columns
+)
+
+var (
+	// ErrInvalidQuery indicates that the --query expression is not valid or does not match the result
+	ErrInvalidQuery = errors.New("invalid query")
+
+	outputFormats = []string{OutputText, OutputJSON, OutputYAML, OutputTable}
+)
+
+// WithOutputFlags returns the flags used by Output. They are part of WithGlobalFlags.
+func WithOutputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "output format, one of " + strings.Join(outputFormats, "|"),
+			Aliases: []string{"o"},
+			Value:   OutputText,
+		},
+		&cli.StringSliceFlag{
+			Name:  "columns",
+			Usage: "columns of the table output, e.g. --columns key,value",
+		},
+		&cli.StringFlag{
+			Name:  "query",
+			Usage: "select fields from the result, e.g. '.credentials.client_id' or '.[].key'",
+		},
+	}
+}
+
+// checkOutputFlags verifies the values of the flags from WithOutputFlags
+func checkOutputFlags(c *cli.Context) error {
+	format := c.String("output")
+	for _, f := range outputFormats {
+		if format == "" || format == f {
+			_, err := parseQuery(c.String("query"))
+			return err
+		}
+	}
+	return fmt.Errorf("%w: --output %s", ErrInvalidFlag, format)
+}
+
+// Output renders the result of a command in the format selected with --output to the app's writer.
+func Output(c *cli.Context, v interface{}) error {
+	return Render(c.App.Writer, v, c.String("output"), c.String("query"), c.StringSlice("columns"))
+}
+
+// Render writes v in the format provided. The query selects parts of the result, the columns
+// select and order the columns of tables.
+func Render(w io.Writer, v interface{}, format, query string, columns []string) error {
+	if format == "" {
+		format = OutputText
+	}
+
+	// text without a query is the result's own representation
+	if format == OutputText && query == "" {
+		if s, ok := v.(fmt.Stringer); ok {
+			_, err := fmt.Fprint(w, s.String())
+			return err
+		}
+	}
+
+	order := fieldOrder(reflect.TypeOf(v))
+
+	generic, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	if query != "" {
+		path, err := parseQuery(query)
+		if err != nil {
+			return err
+		}
+		if generic, err = path.apply(generic); err != nil {
+			return err
+		}
+		order = nil // the structure changed
+	}
+
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(generic)
+	case OutputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(generic); err != nil {
+			return err
+		}
+		return enc.Close()
+	case OutputTable:
+		return renderTable(w, generic, order, columns, true)
+	case OutputText:
+		if m, ok := generic.(map[string]interface{}); ok && len(columns) == 0 {
+			return renderFields(w, m, order)
+		}
+		return renderTable(w, generic, order, columns, false)
+	}
+	return fmt.Errorf("%w: --output %s", ErrInvalidFlag, format)
+}
+
+// renderTable writes lists as one row per item, objects as one row and anything else as is
+func renderTable(w io.Writer, v interface{}, order, columns []string, header bool) error {
+	var rows []map[string]interface{}
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				// a list of values, one per line
+				for _, item := range t {
+					fmt.Fprintln(w, cellValue(item))
+				}
+				return nil
+			}
+			rows = append(rows, m)
+		}
+	case map[string]interface{}:
+		rows = append(rows, t)
+	default:
+		_, err := fmt.Fprintln(w, cellValue(t))
+		return err
+	}
+
+	cols, err := tableColumns(rows, order, columns)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if header {
+		names := make([]string, len(cols))
+		for i, c := range cols {
+			names[i] = strings.ToUpper(c)
+		}
+		fmt.Fprintln(tw, strings.Join(names, "\t"))
+	}
+	for _, row := range rows {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			cells[i] = cellValue(row[c])
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// renderFields writes one line per field of an object
+func renderFields(w io.Writer, m map[string]interface{}, order []string) error {
+	cols, _ := tableColumns([]map[string]interface{}{m}, order, nil)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range cols {
+		fmt.Fprintf(tw, "%s\t%s\n", c, cellValue(m[c]))
+	}
+	return tw.Flush()
+}
+
+// tableColumns returns the selected columns, or all columns in the order of the result's fields
+func tableColumns(rows []map[string]interface{}, order, selected []string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, row := range rows {
+		for k := range row {
+			known[k] = true
+		}
+	}
+
+	if len(selected) > 0 {
+		for _, c := range selected {
+			if !known[c] && len(rows) > 0 {
+				return nil, fmt.Errorf("%w: unknown column '%s'", ErrInvalidFlag, c)
+			}
+		}
+		return selected, nil
+	}
+
+	cols := make([]string, 0, len(known))
+	for _, c := range order {
+		if known[c] {
+			cols = append(cols, c)
+			delete(known, c)
+		}
+	}
+	rest := make([]string, 0, len(known))
+	for c := range known {
+		rest = append(rest, c)
+	}
+	sort.Strings(rest)
+
+	return append(cols, rest...), nil
+}
+
+// cellValue formats a value for tables, lists are comma separated and objects are JSON
+func cellValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, item := range t {
+			parts[i] = cellValue(item)
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		data, _ := json.Marshal(t)
+		return string(data)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// toGeneric converts v into maps, lists and values, using the JSON representation of v
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(generic), nil
+}
+
+// normalizeNumbers replaces json.Number, otherwise large integers end up as floats
+func normalizeNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case []interface{}:
+		for i := range t {
+			t[i] = normalizeNumbers(t[i])
+		}
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = normalizeNumbers(t[k])
+		}
+	}
+	return v
+}
+
+// fieldOrder returns the JSON names of a struct's fields, or the fields of a list's items
+func fieldOrder(t reflect.Type) []string {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+type (
+	// query is a parsed --query expression
+	query []querySegment
+
+	querySegment struct {
+		field   string // select a field of an object
+		index   int    // select an item of a list, if isIndex
+		isIndex bool
+		all     bool // apply the rest of the query to all items of a list
+	}
+)
+
+// parseQuery parses expressions like '.a.b', '.items[0].name', '.[].key' or '.'
+func parseQuery(expr string) (query, error) {
+	q := make(query, 0)
+
+	s := strings.TrimSpace(expr)
+	if s == "" || s == "." {
+		return q, nil
+	}
+	if !strings.HasPrefix(s, ".") && !strings.HasPrefix(s, "[") {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[]")
+			if end < 0 {
+				end = len(s)
+			}
+			if end < len(s) && s[end] == ']' {
+				return nil, fmt.Errorf("%w: '%s'", ErrInvalidQuery, expr)
+			}
+			if end == 0 {
+				if len(s) > 0 && s[0] == '[' {
+					continue
+				}
+				return nil, fmt.Errorf("%w: '%s'", ErrInvalidQuery, expr)
+			}
+			q = append(q, querySegment{field: s[:end]})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: '%s'", ErrInvalidQuery, expr)
+			}
+			idx := strings.TrimSpace(s[1:end])
+			if idx == "" {
+				q = append(q, querySegment{all: true})
+			} else {
+				i, err := strconv.Atoi(idx)
+				if err != nil {
+					return nil, fmt.Errorf("%w: '%s'", ErrInvalidQuery, expr)
+				}
+				q = append(q, querySegment{index: i, isIndex: true})
+			}
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidQuery, expr)
+		}
+	}
+	return q, nil
+}
+
+// apply evaluates the query. Missing fields are nil, negative indices count from the end.
+func (q query) apply(v interface{}) (interface{}, error) {
+	for i, seg := range q {
+		switch {
+		case seg.all:
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: not a list", ErrInvalidQuery)
+			}
+			result := make([]interface{}, len(list))
+			for j, item := range list {
+				r, err := q[i+1:].apply(item)
+				if err != nil {
+					return nil, err
+				}
+				result[j] = r
+			}
+			return result, nil
+		case seg.isIndex:
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: not a list", ErrInvalidQuery)
+			}
+			idx := seg.index
+			if idx < 0 {
+				idx += len(list)
+			}
+			if idx < 0 || idx >= len(list) {
+				return nil, nil
+			}
+			v = list[idx]
+		default:
+			if v == nil {
+				return nil, nil
+			}
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: '%s' is not a field", ErrInvalidQuery, seg.field)
+			}
+			v = m[seg.field]
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/apikit/config"
+)
+
+type (
+	testItem struct {
+		Name  string   `json:"name"`
+		Count int64    `json:"count"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+
+	testResult struct {
+		Title string     `json:"title"`
+		Items []testItem `json:"items"`
+	}
+)
+
+func (r *testResult) String() string {
+	return "title: " + r.Title + "\n"
+}
+
+var result = &testResult{
+	Title: "test",
+	Items: []testItem{
+		{Name: "one", Count: 1700000000, Tags: []string{"a", "b"}},
+		{Name: "two", Count: 2},
+	},
+}
+
+func render(t *testing.T, v interface{}, format, query string, columns ...string) string {
+	var buf bytes.Buffer
+	assert.NoError(t, Render(&buf, v, format, query, columns))
+	return buf.String()
+}
+
+func TestRenderFormats(t *testing.T) {
+	assert.Equal(t, "title: test\n", render(t, result, OutputText, ""))
+	assert.Equal(t, "title: test\n", render(t, result, "", ""))
+
+	var decoded testResult
+	assert.NoError(t, json.Unmarshal([]byte(render(t, result, OutputJSON, "")), &decoded))
+	assert.Equal(t, *result, decoded)
+
+	yaml := render(t, result, OutputYAML, "")
+	assert.Contains(t, yaml, "title: test\n")
+	assert.Contains(t, yaml, "count: 1700000000\n")
+
+	table := render(t, result.Items, OutputTable, "")
+	assert.Equal(t, "NAME  COUNT       TAGS\none   1700000000  a,b\ntwo   2           \n", table)
+
+	// no String() method
+	assert.Equal(t, "name   two\ncount  2\n", render(t, &testItem{Name: "two", Count: 2}, OutputText, ""))
+
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Render(&buf, result, "xml", "", nil), ErrInvalidFlag)
+}
+
+func TestRenderColumns(t *testing.T) {
+	assert.Equal(t, "COUNT       NAME\n1700000000  one\n2           two\n", render(t, result.Items, OutputTable, "", "count", "name"))
+	assert.Equal(t, "one\ntwo\n", render(t, result.Items, OutputText, "", "name"))
+
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Render(&buf, result.Items, OutputTable, "", []string{"unknown"}), ErrInvalidFlag)
+}
+
+func TestRenderQuery(t *testing.T) {
+	assert.Equal(t, "test\n", render(t, result, OutputText, ".title"))
+	assert.Equal(t, "one\ntwo\n", render(t, result, OutputText, ".items[].name"))
+	assert.Equal(t, "two\n", render(t, result, OutputText, "items[-1].name"))
+	assert.Equal(t, "a\nb\n", render(t, result, OutputText, ".items[0].tags"))
+	assert.Equal(t, "\"two\"\n", render(t, result, OutputJSON, ".items[1].name"))
+	assert.Equal(t, "null\n", render(t, result, OutputJSON, ".missing.field"))
+	assert.Equal(t, "NAME\none\ntwo\n", render(t, result, OutputTable, ".items", "name"))
+
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Render(&buf, result, OutputJSON, ".title[]", nil), ErrInvalidQuery)
+	assert.ErrorIs(t, Render(&buf, result, OutputJSON, ".items.name", nil), ErrInvalidQuery)
+
+	for _, q := range []string{"..", ".items[", ".items[x]", "items]"} {
+		_, err := parseQuery(q)
+		assert.ErrorIs(t, err, ErrInvalidQuery, q)
+	}
+}
+
+func TestOutputFlags(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(WithConfigCommands())
+
+	var buf bytes.Buffer
+	app.Writer = &buf
+
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "--output", "json", "config", "get", "endpoint"}))
+	var s settingResult
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &s))
+	assert.Equal(t, config.KeyEndpoint, s.Key)
+	assert.Equal(t, config.SourceDefault.String(), s.Source)
+
+	buf.Reset()
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "-o", "json", "--query", ".[0].name", "config", "profiles", "list"}))
+	assert.Equal(t, "\"default\"\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, app.Run([]string{"test", "--config", dir, "-o", "table", "--columns", "key,source", "config", "list"}))
+	assert.Contains(t, buf.String(), "KEY")
+	assert.NotContains(t, buf.String(), "VALUE")
+
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "-o", "xml", "config", "list"}))
+	assert.Error(t, app.Run([]string{"test", "--config", dir, "--query", "..", "config", "list"}))
+}
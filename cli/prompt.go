@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+// Commands ask for missing values if stdin is a terminal, unless --no-input is set. If stdin is
+// piped, secrets like passphrases and tokens are read from it, one per line, so that they don't
+// end up in the shell history:
+//
+//	cat token.txt | app auth login
+
+type (
+	// promptFunc asks for a value, masked values are not echoed. Validate is called on each
+	// input until it returns nil.
+	promptFunc func(c *cli.Context, label string, mask bool, validate func(string) error) (string, error)
+
+	// nopWriteCloser adds a Close() to a writer, promptui wants one
+	nopWriteCloser struct {
+		io.Writer
+	}
+)
+
+var (
+	// ErrPassphraseMismatch indicates that the passphrase and its confirmation differ
+	ErrPassphraseMismatch = errors.New("the passphrases do not match")
+
+	// prompt asks the user and terminal checks stdin, both are replaced in tests
+	prompt   promptFunc = promptTerminal
+	terminal            = isTerminal
+)
+
+// WithInputFlags returns the flags that control prompting. They are part of WithGlobalFlags.
+func WithInputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-input",
+			Usage: "never prompt, fail if a required value is missing",
+		},
+	}
+}
+
+// IsInteractive reports if the command can prompt for missing values
+func IsInteractive(c *cli.Context) bool {
+	return !c.Bool("no-input") && terminal(c.App.Reader)
+}
+
+// IsPiped reports if stdin is a pipe or a file, i.e. values can be read from it
+func IsPiped(c *cli.Context) bool {
+	return c.App.Reader != nil && !terminal(c.App.Reader)
+}
+
+// readSecret reads one line from stdin, "" at the end of the input
+func readSecret(c *cli.Context) (string, error) {
+	line, err := bufio.NewReader(c.App.Reader).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptTerminal asks for a value with promptui, the prompt is written to stderr
+func promptTerminal(c *cli.Context, label string, mask bool, validate func(string) error) (string, error) {
+	p := promptui.Prompt{
+		Label:    label,
+		Validate: validate,
+		Stdin:    io.NopCloser(c.App.Reader),
+		Stdout:   nopWriteCloser{c.App.ErrWriter},
+	}
+	if mask {
+		p.Mask = '*'
+	}
+
+	value, err := p.Run()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+func validateEmail(s string) error {
+	_, err := mail.ParseAddress(s)
+	return err
+}
+
+func validateNotEmpty(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return ErrInvalidArgument
+	}
+	return nil
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestReadSecret(t *testing.T) {
+	app := &cli.App{Reader: strings.NewReader("  secret words \nnext\n")}
+	c := cli.NewContext(app, nil, nil)
+
+	s, err := readSecret(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret words", s)
+
+	app.Reader = strings.NewReader("no newline")
+	s, err = readSecret(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "no newline", s)
+
+	app.Reader = strings.NewReader("")
+	s, err = readSecret(c)
+	assert.NoError(t, err)
+	assert.Empty(t, s)
+}
+
+func TestIsTerminal(t *testing.T) {
+	assert.False(t, isTerminal(strings.NewReader("")))
+
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.False(t, isTerminal(f))
+}
+
+func TestValidators(t *testing.T) {
+	assert.NoError(t, validateEmail("me@example.com"))
+	assert.Error(t, validateEmail("me"))
+	assert.NoError(t, validateNotEmpty("x"))
+	assert.Error(t, validateNotEmpty("  "))
+	assert.NoError(t, validatePassphrase(""))
+	assert.Error(t, validatePassphrase("too short"))
+}
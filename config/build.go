@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+// Build metadata is read from the module's build info and the VCS stamp added by 'go build'.
+// Both can be overridden at build time, e.g.
+//
+//	go build -ldflags "-X github.com/txsvc/apikit/config.buildVersion=v1.2.3 \
+//		-X github.com/txsvc/apikit/config.buildCommit=$(git rev-parse HEAD) \
+//		-X github.com/txsvc/apikit/config.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ) \
+//		-X github.com/txsvc/apikit/config.buildDirty=false"
+
+const (
+	// length of the abbreviated commit hash in version strings
+	shortCommitLength = 7
+)
+
+var (
+	// ErrInvalidVersion indicates that a version is not a semantic version like '1.2.3'
+	ErrInvalidVersion = errors.New("invalid version")
+)
+
+// set with -ldflags
+var (
+	buildVersion string // e.g. 'v1.2.3' or 'v1.2.3-rc.1'
+	buildCommit  string // the full commit hash
+	buildTime    string // RFC 3339
+	buildDirty   string // 'true' if built from a modified working tree
+)
+
+// InfoFromBuild returns the app info with the version, commit, commit time, build time and dirty
+// flag of the binary. The version numbers provided are used if the build has no release version,
+// e.g. with 'go run' or a local 'go build', see IsReleaseVersion. The build time is only known if
+// it was set with -ldflags, the VCS stamp only has the time of the commit.
+func InfoFromBuild(name, shortName, copyright, about string, major, minor, fix int) Info {
+	info := NewAppInfo(name, shortName, copyright, about, major, minor, fix)
+
+	version, commit, committed, dirty := "", "", "", ""
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if IsReleaseVersion(bi.Main.Version) {
+			version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				commit = s.Value
+			case "vcs.time":
+				committed = s.Value
+			case "vcs.modified":
+				dirty = s.Value
+			}
+		}
+	}
+
+	// explicit values take precedence
+	version = takeOne(buildVersion, version)
+	commit = takeOne(buildCommit, commit)
+	dirty = takeOne(buildDirty, dirty)
+
+	if major, minor, fix, pre, ok := parseVersion(version); ok {
+		info.majorVersion, info.minorVersion, info.fixVersion = major, minor, fix
+		info.prerelease = pre
+	}
+	info.commit = commit
+	if t, err := time.Parse(time.RFC3339, committed); err == nil {
+		info.commitTime = t
+	}
+	if t, err := time.Parse(time.RFC3339, buildTime); err == nil {
+		info.buildTime = t
+	}
+	info.dirty, _ = strconv.ParseBool(dirty)
+
+	return info
+}
+
+// IsReleaseVersion reports if v is a semantic version of a release or prerelease. Development
+// builds are not, e.g. '(devel)' or the pseudo-versions like 'v0.0.0-20231019160301-5fec11645c6f'
+// that 'go build' stamps binaries built from a VCS checkout with since Go 1.24.
+func IsReleaseVersion(v string) bool {
+	if module.IsPseudoVersion("v" + strings.TrimPrefix(v, "v")) {
+		return false
+	}
+	_, _, _, _, ok := parseVersion(v)
+	return ok
+}
+
+// parseVersion parses versions like 'v1.2.3', '1.2.3-rc.1' or 'v1.2.3+incompatible'
+func parseVersion(v string) (int, int, int, string, bool) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+") // drop build metadata
+	v, pre, _ := strings.Cut(v, "-")
+
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return 0, 0, 0, "", false
+	}
+
+	numbers := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, 0, 0, "", false
+		}
+		numbers[i] = n
+	}
+	return numbers[0], numbers[1], numbers[2], pre, true
+}
+
+// CompareVersions compares two semantic versions and ignores build metadata. The result is -1 if
+// a < b, 0 if a == b and +1 if a > b. Prereleases precede their release, e.g. 1.2.3-rc.1 < 1.2.3.
+func CompareVersions(a, b string) (int, error) {
+	aMajor, aMinor, aFix, aPre, ok := parseVersion(a)
+	if !ok {
+		return 0, fmt.Errorf("%w: '%s'", ErrInvalidVersion, a)
+	}
+	bMajor, bMinor, bFix, bPre, ok := parseVersion(b)
+	if !ok {
+		return 0, fmt.Errorf("%w: '%s'", ErrInvalidVersion, b)
+	}
+
+	for _, d := range []int{aMajor - bMajor, aMinor - bMinor, aFix - bFix} {
+		if d != 0 {
+			return sign(d), nil
+		}
+	}
+	return comparePrerelease(aPre, bPre), nil
+}
+
+// comparePrerelease compares the dot separated identifiers of prereleases, see semver.org
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aErr == nil:
+			return -1 // numeric identifiers have lower precedence
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func takeOne(valid, or string) string {
+	if len(valid) > 0 {
+		return valid
+	}
+	return or
+}
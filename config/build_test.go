@@ -0,0 +1,91 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	major, minor, fix, pre, ok := parseVersion("v1.2.3")
+	assert.True(t, ok)
+	assert.Equal(t, []int{1, 2, 3}, []int{major, minor, fix})
+	assert.Empty(t, pre)
+
+	_, _, _, pre, ok = parseVersion("1.2.3-rc.1+incompatible")
+	assert.True(t, ok)
+	assert.Equal(t, "rc.1", pre)
+
+	_, _, _, _, ok = parseVersion("(devel)")
+	assert.False(t, ok)
+	_, _, _, _, ok = parseVersion("v1.2")
+	assert.False(t, ok)
+}
+
+func TestIsReleaseVersion(t *testing.T) {
+	assert.True(t, IsReleaseVersion("v1.2.3"))
+	assert.True(t, IsReleaseVersion("1.2.3-rc.1+a1b2c3d"))
+	assert.True(t, IsReleaseVersion("v0.0.0"))
+
+	assert.False(t, IsReleaseVersion(""))
+	assert.False(t, IsReleaseVersion("(devel)"))
+	assert.False(t, IsReleaseVersion("v0.0.0-20261019160301-5fec11645c6f+dirty"))
+	assert.False(t, IsReleaseVersion("0.0.0-20261019160301-5fec11645c6f+5fec116"))
+	assert.False(t, IsReleaseVersion("v1.2.4-0.20261019160301-5fec11645c6f"))
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3+a1b2c3d.dirty", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2.3-rc.1", "1.2.3", -1},
+		{"1.2.3-alpha", "1.2.3-beta", -1},
+		{"1.2.3-rc.2", "1.2.3-rc.10", -1},
+		{"1.2.3-rc.1", "1.2.3-rc", 1},
+		{"1.2.3-1", "1.2.3-alpha", -1},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.a+" <> "+tt.b)
+	}
+
+	_, err := CompareVersions("1.2", "1.2.3")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+	_, err = CompareVersions("1.2.3", "latest")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestInfoFromBuild(t *testing.T) {
+	// tests are not built with version or VCS info
+	info := InfoFromBuild("test", "t", "copyright", "about", 0, 1, 0)
+	assert.Equal(t, "0.1.0", info.VersionString())
+	assert.Equal(t, "t 0.1.0", info.UserAgentString())
+	assert.True(t, info.CommitTime().IsZero())
+	assert.True(t, info.BuildTime().IsZero())
+
+	buildVersion, buildCommit, buildTime, buildDirty = "v1.2.3-rc.1", "a1b2c3d4e5f6", "2022-06-01T12:00:00Z", "true"
+	defer func() {
+		buildVersion, buildCommit, buildTime, buildDirty = "", "", "", ""
+	}()
+
+	info = InfoFromBuild("test", "t", "copyright", "about", 0, 1, 0)
+	assert.Equal(t, 1, info.MajorVersion())
+	assert.Equal(t, 2, info.MinorVersion())
+	assert.Equal(t, 3, info.FixVersion())
+	assert.Equal(t, "rc.1", info.Prerelease())
+	assert.Equal(t, "a1b2c3d4e5f6", info.Commit())
+	assert.Equal(t, time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC), info.BuildTime())
+	assert.True(t, info.Dirty())
+
+	assert.Equal(t, "1.2.3-rc.1+a1b2c3d.dirty", info.VersionString())
+	assert.Equal(t, "t 1.2.3-rc.1+a1b2c3d.dirty", info.UserAgentString())
+	assert.Equal(t, "t 1.2.3-rc.1+a1b2c3d.dirty", info.ServerString())
+}
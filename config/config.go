@@ -3,6 +3,8 @@ package config
 import (
 	"errors"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/txsvc/cloudlib/helpers"
 	"github.com/txsvc/cloudlib/settings"
@@ -14,13 +16,15 @@ const (
 	ConfigDirLocationENV = "CONFIG_LOCATION" // config settings
 	AppSessionKeyENV     = "APP_SESSION_KEY" // Session/Auth key used to encrypt cookies with
 
+	AppSessionKeyPreviousENV = "APP_SESSION_KEY_PREVIOUS" // comma separated list of retired session keys, still accepted to decrypt cookies
+
 	APIEndpointENV = "API_ENDPOINT" // client settings
 	ForceTraceENV  = "API_FORCE_TRACE"
 
 	// Other constants
 	DefaultConfigName          = "config"
-	DefaultConfigLocation      = "./.config"
-	DefaultCredentialsLocation = "./.secrets"
+	DefaultConfigLocation      = "./.config"             // project-local, see UserConfigLocation()
+	DefaultCredentialsLocation = "./.secrets"            // project-local, see SecretsLocation()
 	DefaultEndpoint            = "http://localhost:8080" // only really useful for testing ...
 )
 
@@ -41,6 +45,16 @@ type (
 		minorVersion int
 		// fixVersion: the fix/patch version of the service/api
 		fixVersion int
+		// prerelease: e.g. 'rc.1', empty for releases
+		prerelease string
+		// commit: the VCS revision the binary was built from
+		commit string
+		// commitTime: the time of the commit the binary was built from
+		commitTime time.Time
+		// buildTime: the time of the build, if set with -ldflags
+		buildTime time.Time
+		// dirty: true if the binary was built from a modified working tree
+		dirty bool
 	}
 
 	ConfigProvider interface {
@@ -49,9 +63,11 @@ type (
 		// Settings returns the app settings, if configured, or falls back to a default, minimal configuration
 		Settings() *settings.DialSettings
 		// ConfigLocation returns the path to the config location, if set, or the default location otherwise.
-		ConfigLocation() string // './.config' unless explicitly set.
+		ConfigLocation() string // the user's config directory unless explicitly set.
 		// SetConfigLocation explicitly sets the location where the configuration is expected. The location's existence is NOT verified.
 		SetConfigLocation(string)
+		// Validate checks the settings and returns ValidationErrors with all problems found
+		Validate() error
 	}
 )
 
@@ -72,7 +88,7 @@ var (
 
 func init() {
 	// makes sure that SOMETHING is initialized
-	SetProvider(NewLocalConfigProvider())
+	SetProvider(providerFromEnv())
 }
 
 func SetProvider(provider ConfigProvider) {
@@ -91,7 +107,28 @@ func SetConfigLocation(loc string) {
 	config_.SetConfigLocation(loc)
 }
 
+// SettingsError returns the error of reading the files the settings are resolved from, nil if
+// there was none. The settings are usable anyway, without what could not be read, e.g. without
+// the credentials if they could not be decrypted (ErrNoSecretsKey, ErrDecryptingSecrets).
+func SettingsError() error {
+	if r, ok := config_.(interface{ settingsError() error }); ok {
+		return r.settingsError()
+	}
+	return nil
+}
+
 // AppSessionKey is initialized from ENV['APP_SESSION_KEY'] or randomly generated on startup, if not provided.
 func AppSessionKey() string {
 	return sessionKey
 }
+
+// AppSessionKeys returns the current session key, followed by all retired keys from ENV['APP_SESSION_KEY_PREVIOUS'].
+func AppSessionKeys() []string {
+	keys := []string{sessionKey}
+	for _, k := range strings.Split(stdlib.GetString(AppSessionKeyPreviousENV, ""), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+)
+
+// The environment-only config provider is meant for containers and other read-only deployments.
+// Info and settings are built from the built-in defaults, APIKIT_* environment variables and
+// command line flags. No files are read or written, i.e. there are no profiles, no config file
+// and no stored credentials. Select it with ENV['APIKIT_CONFIG_PROVIDER']=env or SetProvider().
+
+const (
+	// ConfigProviderENV selects the config provider used by default, 'local' or 'env'
+	ConfigProviderENV = "APIKIT_CONFIG_PROVIDER"
+
+	ProviderLocal = "local" // files in ConfigLocation(), the default
+	ProviderEnv   = "env"   // environment variables only
+
+	// app info
+	AppNameENV      = "APIKIT_APP_NAME"
+	AppShortNameENV = "APIKIT_APP_SHORTNAME"
+	AppCopyrightENV = "APIKIT_APP_COPYRIGHT"
+	AppAboutENV     = "APIKIT_APP_ABOUT"
+	AppVersionENV   = "APIKIT_APP_VERSION" // e.g. 'v1.2.3' or '1.2.3-rc.1'
+)
+
+type (
+	envConfig struct {
+		// the interface to implement
+		ConfigProvider
+
+		// app info
+		info *Info
+		// all layers of settings
+		layers atomic.Pointer[Layers]
+	}
+)
+
+var (
+	// ErrReadOnlyConfiguration indicates that the config provider does not store settings
+	ErrReadOnlyConfiguration = errors.New("read-only configuration")
+)
+
+// NewEnvConfigProvider returns a config provider that never touches the file system. The info
+// provided is used unless it is overridden by APIKIT_APP_* environment variables.
+func NewEnvConfigProvider(info Info) ConfigProvider {
+	info.name = stdlib.GetString(AppNameENV, info.name)
+	info.shortName = stdlib.GetString(AppShortNameENV, info.shortName)
+	info.copyright = stdlib.GetString(AppCopyrightENV, info.copyright)
+	info.about = stdlib.GetString(AppAboutENV, info.about)
+	if major, minor, fix, pre, ok := parseVersion(stdlib.GetString(AppVersionENV, "")); ok {
+		info.majorVersion, info.minorVersion, info.fixVersion = major, minor, fix
+		info.prerelease = pre
+	}
+
+	return &envConfig{
+		info: &info,
+	}
+}
+
+func (c *envConfig) Info() *Info {
+	return c.info
+}
+
+// ConfigLocation returns "" since there are no files
+func (c *envConfig) ConfigLocation() string {
+	return ""
+}
+
+// SetConfigLocation does nothing since there are no files
+func (c *envConfig) SetConfigLocation(loc string) {}
+
+// Settings resolves the settings from the built-in defaults, APIKIT_* environment variables
+// and command line flags, in that order of precedence.
+func (c *envConfig) Settings() *settings.DialSettings {
+	return c.Layers().DialSettings()
+}
+
+// Validate checks the effective settings
+func (c *envConfig) Validate() error {
+	return ValidateSettings(c.Settings())
+}
+
+// Layers returns the layers the settings are resolved from
+func (c *envConfig) Layers() *Layers {
+	if l := c.layers.Load(); l != nil {
+		return l
+	}
+
+	l := c.resolve()
+	if !c.layers.CompareAndSwap(nil, l) {
+		return c.layers.Load() // someone else was faster
+	}
+	return l
+}
+
+// reload reads the environment again and swaps the settings, unless the new settings are invalid
+func (c *envConfig) reload() (*Layers, *Layers, error) {
+	l := c.resolve()
+	if err := ValidateSettings(l.DialSettings()); err != nil {
+		return nil, nil, err
+	}
+
+	old := c.Layers()
+	c.layers.Store(l)
+
+	return old, l, nil
+}
+
+func (c *envConfig) reset() {
+	c.layers.Store(nil)
+}
+
+func (c *envConfig) readOnly() bool {
+	return true
+}
+
+func (c *envConfig) resolve() *Layers {
+	l := NewLayers()
+	l.Replace(SourceDefault, FlattenDialSettings(&settings.DialSettings{
+		Endpoint:      DefaultEndpoint,
+		Credentials:   &settings.Credentials{}, // add this to avoid NPEs further down
+		DefaultScopes: defaultScopes(),
+		UserAgent:     c.info.UserAgentString(),
+	}))
+	l.Replace(SourceEnv, EnvValues())
+	l.Replace(SourceFlag, FlagValues())
+
+	return l
+}
+
+// providerFromEnv returns the config provider selected with ENV['APIKIT_CONFIG_PROVIDER']
+func providerFromEnv() ConfigProvider {
+	if strings.EqualFold(stdlib.GetString(ConfigProviderENV, ProviderLocal), ProviderEnv) {
+		return NewEnvConfigProvider(defaultInfo())
+	}
+	return NewLocalConfigProvider()
+}
+
+// checkWritable returns ErrReadOnlyConfiguration if the config provider does not store settings
+func checkWritable() error {
+	if ro, ok := config_.(interface{ readOnly() bool }); ok && ro.readOnly() {
+		return ErrReadOnlyConfiguration
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/apikit/auth"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv(AppNameENV, "container kit")
+	t.Setenv(AppShortNameENV, "ck")
+	t.Setenv(AppVersionENV, "v2.3.4-rc.1")
+	t.Setenv(EnvName(KeyEndpoint), "https://api.example.com")
+	t.Setenv(EnvName(KeyScopes), "api:read,api:write")
+	t.Setenv(EnvName(KeyToken), "token")
+	t.Setenv(EnvName(KeyOptionPrefix+"foo"), "bar")
+
+	cfg := NewEnvConfigProvider(defaultInfo())
+	SetProvider(cfg)
+	defer SetProvider(NewLocalConfigProvider())
+
+	info := cfg.Info()
+	assert.Equal(t, "container kit", info.Name())
+	assert.Equal(t, "ck", info.ShortName())
+	assert.Equal(t, 2, info.MajorVersion())
+	assert.Equal(t, "rc.1", info.Prerelease())
+
+	ds := cfg.Settings()
+	assert.Equal(t, "https://api.example.com", ds.Endpoint)
+	assert.Equal(t, []string{"api:read", "api:write"}, ds.Scopes)
+	assert.Equal(t, []string{auth.ScopeApiRead}, ds.DefaultScopes)
+	assert.Equal(t, "token", ds.Credentials.Token)
+	assert.Equal(t, "bar", ds.GetOption("foo"))
+	assert.Equal(t, info.UserAgentString(), ds.UserAgent)
+
+	assert.Empty(t, cfg.ConfigLocation())
+	cfg.SetConfigLocation(t.TempDir())
+	assert.Empty(t, cfg.ConfigLocation())
+
+	s, ok := Lookup(KeyEndpoint)
+	assert.True(t, ok)
+	assert.Equal(t, SourceEnv, s.Source)
+}
+
+func TestEnvProviderReload(t *testing.T) {
+	t.Setenv(EnvName(KeyEndpoint), "https://one.example.com")
+
+	SetProvider(NewEnvConfigProvider(defaultInfo()))
+	defer SetProvider(NewLocalConfigProvider())
+
+	assert.Equal(t, "https://one.example.com", GetConfig().Settings().Endpoint)
+
+	t.Setenv(EnvName(KeyEndpoint), "https://two.example.com")
+	assert.Equal(t, "https://one.example.com", GetConfig().Settings().Endpoint)
+	assert.NoError(t, Reload())
+	assert.Equal(t, "https://two.example.com", GetConfig().Settings().Endpoint)
+
+	// invalid settings are not used
+	t.Setenv(EnvName(KeyEndpoint), "not a url")
+	assert.Error(t, Reload())
+	assert.Equal(t, "https://two.example.com", GetConfig().Settings().Endpoint)
+}
+
+func TestEnvProviderReadOnly(t *testing.T) {
+	SetProvider(NewEnvConfigProvider(defaultInfo()))
+	defer SetProvider(NewLocalConfigProvider())
+
+	assert.ErrorIs(t, SaveSettings(GetConfig().Settings()), ErrReadOnlyConfiguration)
+	assert.ErrorIs(t, CreateProfile("staging", GetConfig().Settings()), ErrReadOnlyConfiguration)
+	assert.ErrorIs(t, UseProfile(DefaultProfile), ErrReadOnlyConfiguration)
+	assert.ErrorIs(t, RekeySecrets(""), ErrReadOnlyConfiguration)
+	_, err := MigrateConfig(false)
+	assert.ErrorIs(t, err, ErrReadOnlyConfiguration)
+
+	assertProfile(t, DefaultProfile)
+	assert.NoError(t, GetConfig().Validate())
+}
+
+func TestProviderFromEnv(t *testing.T) {
+	t.Setenv(ConfigProviderENV, "")
+	_, ok := providerFromEnv().(*localConfig)
+	assert.True(t, ok)
+
+	t.Setenv(ConfigProviderENV, ProviderEnv)
+	_, ok = providerFromEnv().(*envConfig)
+	assert.True(t, ok)
+}
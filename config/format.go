@@ -0,0 +1,430 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+const (
+	// supported config file formats
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+
+	// configFilePerm is used for all files that might contain credentials
+	configFilePerm fs.FileMode = 0600
+	indentChar                 = "  "
+)
+
+type (
+	// fileSettings mirrors settings.DialSettings with tags for all supported formats
+	fileSettings struct {
+		Version       int               `json:"version,omitempty" yaml:"version,omitempty" toml:"version,omitempty"`
+		Endpoint      string            `json:"endpoint,omitempty" yaml:"endpoint,omitempty" toml:"endpoint,omitempty"`
+		Credentials   *fileCredentials  `json:"credentials,omitempty" yaml:"credentials,omitempty" toml:"credentials,omitempty"`
+		Scopes        []string          `json:"scopes,omitempty" yaml:"scopes,omitempty" toml:"scopes,omitempty"`
+		DefaultScopes []string          `json:"default_scopes,omitempty" yaml:"default_scopes,omitempty" toml:"default_scopes,omitempty"`
+		UserAgent     string            `json:"user_agent,omitempty" yaml:"user_agent,omitempty" toml:"user_agent,omitempty"`
+		Options       map[string]string `json:"options,omitempty" yaml:"options,omitempty" toml:"options,omitempty"`
+		Server        *fileServer       `json:"server,omitempty" yaml:"server,omitempty" toml:"server,omitempty"`
+	}
+
+	// fileServer mirrors ServerSettings. All values are strings, e.g. "8080" or "30s".
+	fileServer struct {
+		Port          string `json:"port,omitempty" yaml:"port,omitempty" toml:"port,omitempty"`
+		ShutdownDelay string `json:"shutdown_delay,omitempty" yaml:"shutdown_delay,omitempty" toml:"shutdown_delay,omitempty"`
+		ReadTimeout   string `json:"read_timeout,omitempty" yaml:"read_timeout,omitempty" toml:"read_timeout,omitempty"`
+		WriteTimeout  string `json:"write_timeout,omitempty" yaml:"write_timeout,omitempty" toml:"write_timeout,omitempty"`
+		LogLevel      string `json:"log_level,omitempty" yaml:"log_level,omitempty" toml:"log_level,omitempty"`
+	}
+
+	// fileCredentials mirrors settings.Credentials
+	fileCredentials struct {
+		ProjectID    string `json:"project_id,omitempty" yaml:"project_id,omitempty" toml:"project_id,omitempty"`
+		ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty" toml:"client_id,omitempty"`
+		ClientSecret string `json:"client_secret,omitempty" yaml:"client_secret,omitempty" toml:"client_secret,omitempty"`
+		Token        string `json:"token,omitempty" yaml:"token,omitempty" toml:"token,omitempty"`
+		Status       int    `json:"status,omitempty" yaml:"status,omitempty" toml:"status,omitempty"`
+		Expires      int64  `json:"expires,omitempty" yaml:"expires,omitempty" toml:"expires,omitempty"`
+	}
+)
+
+var (
+	// extensions of config files, in order of precedence. No extension means JSON.
+	configExtensions = []string{"", ".json", ".yaml", ".yml", ".toml"}
+)
+
+// FormatOf returns the format of a config file, based on its extension.
+func FormatOf(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case "", ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("%w: unsupported format '%s'", ErrInvalidConfiguration, filepath.Ext(path))
+}
+
+// ConfigFileIn returns the config file in dir. If there is none, the default JSON file is returned.
+func ConfigFileIn(dir string) string {
+	for _, ext := range configExtensions {
+		path := filepath.Join(dir, DefaultConfigName+ext)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path
+		}
+	}
+	return filepath.Join(dir, DefaultConfigName)
+}
+
+// ConfigFile returns the config file of the active profile in the current ConfigLocation().
+func ConfigFile() (string, error) {
+	profile, err := CurrentProfile()
+	if err != nil {
+		return "", err
+	}
+	return ConfigFileIn(ProfileLocation(profile)), nil
+}
+
+// SaveSettings writes the settings to ConfigFile(), keeping its format. The credentials are
+// encrypted and stored separately, see WriteCredentials(). The file is written from scratch,
+// comments and the order of keys in the original file are not preserved.
+func SaveSettings(ds *settings.DialSettings) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	profile, err := CurrentProfile()
+	if err != nil {
+		return err
+	}
+	if err := saveSettingsIn(GetConfig().ConfigLocation(), profile, ds); err != nil {
+		return err
+	}
+
+	reset() // resolve the settings again with the new files
+	return nil
+}
+
+// SaveValues writes flat values to ConfigFile(), like SaveSettings, including the server settings.
+func SaveValues(values map[string]string) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	profile, err := CurrentProfile()
+	if err != nil {
+		return err
+	}
+	if err := saveValuesIn(GetConfig().ConfigLocation(), profile, values); err != nil {
+		return err
+	}
+
+	reset() // resolve the settings again with the new files
+	return nil
+}
+
+// StoredSettings returns the settings of the active profile as stored in its config file,
+// including the decrypted credentials. A missing config file is not an error. If the credentials
+// can't be decrypted, the settings are returned without them, together with the error.
+func StoredSettings() (*settings.DialSettings, error) {
+	profile, err := CurrentProfile()
+	if err != nil {
+		return nil, err
+	}
+	values, err := readStoredValues(GetConfig().ConfigLocation(), profile)
+	if values == nil {
+		return nil, err
+	}
+
+	ds := UnflattenDialSettings(values)
+	if err != nil || isEmptyCredentials(ds.Credentials) {
+		ds.Credentials = nil
+	}
+	return ds, err
+}
+
+// ReadFileValues reads the flat values of a JSON, YAML or TOML config file, including the
+// server settings, see FlattenDialSettings. Older files are migrated in memory.
+func ReadFileValues(path string) (map[string]string, error) {
+	f, err := readFileSettings(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.migratedValues()
+}
+
+// WriteFileValues is the reverse of ReadFileValues. The file is stamped with the current schema version.
+func WriteFileValues(values map[string]string, path string) error {
+	f := newFileValues(values)
+	f.Version = CurrentSchemaVersion()
+
+	return writeFileSettings(f, path)
+}
+
+// ReadDialSettings reads a JSON, YAML or TOML config file.
+func ReadDialSettings(path string) (*settings.DialSettings, error) {
+	f, err := readFileSettings(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.dialSettings(), nil
+}
+
+// WriteDialSettings writes a JSON, YAML or TOML config file, depending on the file's extension.
+// The file is stamped with the current schema version.
+func WriteDialSettings(ds *settings.DialSettings, path string) error {
+	f := newFileSettings(ds)
+	f.Version = CurrentSchemaVersion()
+
+	return writeFileSettings(f, path)
+}
+
+// ConvertConfigFile converts a config file into another format, keeping its schema version.
+// Comments are not converted, they are dropped.
+func ConvertConfigFile(from, to string) error {
+	f, err := readFileSettings(from)
+	if err != nil {
+		return err
+	}
+	return writeFileSettings(f, to)
+}
+
+func saveSettingsIn(root, profile string, ds *settings.DialSettings) error {
+	values := FlattenDialSettings(ds)
+
+	// keep the server settings, they are not part of the DialSettings
+	if f, err := readFileSettings(ConfigFileIn(profileDir(root, profile))); err == nil {
+		for k, v := range f.values() {
+			if isServerKey(k) {
+				values[k] = v
+			}
+		}
+	}
+	return saveValuesIn(root, profile, values)
+}
+
+func saveValuesIn(root, profile string, values map[string]string) error {
+	// the credentials first, the config file is left alone if they can't be written
+	if err := WriteCredentials(profile, UnflattenDialSettings(values).Credentials); err != nil {
+		return err
+	}
+
+	f := newFileValues(values)
+	f.Credentials = nil
+	f.Version = CurrentSchemaVersion()
+	return writeFileSettings(f, ConfigFileIn(profileDir(root, profile)))
+}
+
+func readFileSettings(path string) (*fileSettings, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSettings(format, data)
+}
+
+func writeFileSettings(f *fileSettings, path string) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeSettings(format, f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, configFilePerm)
+}
+
+// readStoredValues returns the values of a profile's config file and its decrypted credentials
+func readStoredValues(root, profile string) (map[string]string, error) {
+	f, err := readFileSettings(ConfigFileIn(profileDir(root, profile)))
+	if os.IsNotExist(err) {
+		f, err = &fileSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// older files are migrated in memory, see MigrateConfig()
+	values, err := f.migratedValues()
+	if err != nil {
+		return nil, err
+	}
+
+	// encrypted credentials take precedence over plaintext ones from older config files. If they
+	// can't be decrypted, the values are returned without any credentials.
+	cred, err := ReadCredentials(profile)
+	if err != nil || cred != nil {
+		for _, key := range credentialKeys {
+			delete(values, key)
+		}
+	}
+	if err != nil {
+		return values, fmt.Errorf("credentials of profile '%s': %w", profile, err)
+	}
+	if cred != nil {
+		for k, v := range FlattenDialSettings(&settings.DialSettings{Credentials: cred}) {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+func decodeSettings(format string, data []byte) (*fileSettings, error) {
+	f := fileSettings{}
+
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &f)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &f)
+	case FormatTOML:
+		err = toml.Unmarshal(data, &f)
+	default:
+		err = ErrInvalidConfiguration
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func encodeSettings(format string, f *fileSettings) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(f, "", indentChar)
+	case FormatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(len(indentChar))
+		if err := enc.Encode(f); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), enc.Close()
+	case FormatTOML:
+		var buf bytes.Buffer
+		enc := toml.NewEncoder(&buf)
+		enc.Indent = indentChar
+		if err := enc.Encode(f); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, ErrInvalidConfiguration
+}
+
+func newFileSettings(ds *settings.DialSettings) *fileSettings {
+	f := &fileSettings{
+		Endpoint:      ds.Endpoint,
+		Scopes:        ds.Scopes,
+		DefaultScopes: ds.DefaultScopes,
+		UserAgent:     ds.UserAgent,
+		Options:       ds.Options,
+	}
+	if ds.Credentials != nil {
+		f.Credentials = &fileCredentials{
+			ProjectID:    ds.Credentials.ProjectID,
+			ClientID:     ds.Credentials.ClientID,
+			ClientSecret: ds.Credentials.ClientSecret,
+			Token:        ds.Credentials.Token,
+			Status:       int(ds.Credentials.Status),
+			Expires:      ds.Credentials.Expires,
+		}
+	}
+	return f
+}
+
+func (f *fileSettings) dialSettings() *settings.DialSettings {
+	ds := &settings.DialSettings{
+		Endpoint:      f.Endpoint,
+		Scopes:        f.Scopes,
+		DefaultScopes: f.DefaultScopes,
+		UserAgent:     f.UserAgent,
+		Options:       f.Options,
+	}
+	if f.Credentials != nil {
+		ds.Credentials = &settings.Credentials{
+			ProjectID:    f.Credentials.ProjectID,
+			ClientID:     f.Credentials.ClientID,
+			ClientSecret: f.Credentials.ClientSecret,
+			Token:        f.Credentials.Token,
+			Status:       settings.State(f.Credentials.Status),
+			Expires:      f.Credentials.Expires,
+		}
+	}
+	return ds
+}
+
+// schemaVersion returns the schema version of the file, files without one are version 1
+func (f *fileSettings) schemaVersion() int {
+	if f.Version == 0 {
+		return initialSchemaVersion
+	}
+	return f.Version
+}
+
+// migratedValues returns the values of the file, migrated to the current schema version
+func (f *fileSettings) migratedValues() (map[string]string, error) {
+	version := f.schemaVersion()
+	todo, err := migrationsFrom(version)
+	if err != nil {
+		return nil, err
+	}
+	values := f.values()
+	if err := migrateValues(version, todo, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// values returns the flat values of the file, see FlattenDialSettings
+func (f *fileSettings) values() map[string]string {
+	values := FlattenDialSettings(f.dialSettings())
+	if srv := f.Server; srv != nil {
+		putString(values, KeyPort, srv.Port)
+		putString(values, KeyShutdownDelay, srv.ShutdownDelay)
+		putString(values, KeyReadTimeout, srv.ReadTimeout)
+		putString(values, KeyWriteTimeout, srv.WriteTimeout)
+		putString(values, KeyLogLevel, srv.LogLevel)
+	}
+	return values
+}
+
+// newFileValues is the reverse of values
+func newFileValues(values map[string]string) *fileSettings {
+	f := newFileSettings(UnflattenDialSettings(values))
+	if isEmptyCredentials(f.dialSettings().Credentials) {
+		f.Credentials = nil
+	}
+
+	srv := fileServer{
+		Port:          values[KeyPort],
+		ShutdownDelay: values[KeyShutdownDelay],
+		ReadTimeout:   values[KeyReadTimeout],
+		WriteTimeout:  values[KeyWriteTimeout],
+		LogLevel:      values[KeyLogLevel],
+	}
+	if srv != (fileServer{}) {
+		f.Server = &srv
+	}
+	return f
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func testDialSettings() *settings.DialSettings {
+	return &settings.DialSettings{
+		Endpoint:      "http://localhost:9090",
+		UserAgent:     "test 1.0.0",
+		Scopes:        []string{"api:read", "api:write"},
+		DefaultScopes: []string{"api:read"},
+		Credentials: &settings.Credentials{
+			ProjectID: "project",
+			ClientID:  "client",
+			Token:     "token",
+			Status:    settings.StateAuthorized,
+		},
+		Options: map[string]string{"APIKey": "key"},
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, format := range map[string]string{
+		"config":      FormatJSON,
+		"config.json": FormatJSON,
+		"config.yml":  FormatYAML,
+		"config.YAML": FormatYAML,
+		"config.toml": FormatTOML,
+	} {
+		f, err := FormatOf(path)
+		assert.NoError(t, err)
+		assert.Equal(t, format, f, path)
+	}
+
+	_, err := FormatOf("config.ini")
+	assert.Error(t, err)
+}
+
+func TestReadWriteFormats(t *testing.T) {
+	dir := t.TempDir()
+	ds := testDialSettings()
+
+	for _, name := range []string{"config", "config.json", "config.yaml", "config.toml"} {
+		path := filepath.Join(dir, name)
+
+		assert.NoError(t, WriteDialSettings(ds, path))
+		ds2, err := ReadDialSettings(path)
+		assert.NoError(t, err)
+		assert.Equal(t, ds, ds2, name)
+	}
+
+	// a human readable format
+	data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "endpoint: http://localhost:9090")
+}
+
+func TestConfigFileIn(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, filepath.Join(dir, DefaultConfigName), ConfigFileIn(dir))
+
+	assert.NoError(t, WriteDialSettings(testDialSettings(), filepath.Join(dir, "config.toml")))
+	assert.Equal(t, filepath.Join(dir, "config.toml"), ConfigFileIn(dir))
+
+	// YAML takes precedence over TOML
+	assert.NoError(t, ConvertConfigFile(filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.yaml")))
+	assert.Equal(t, filepath.Join(dir, "config.yaml"), ConfigFileIn(dir))
+}
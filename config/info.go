@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 func NewAppInfo(name, shortName, copyright, about string, major, minor, fix int) Info {
@@ -44,14 +46,55 @@ func (i *Info) FixVersion() int {
 	return i.fixVersion
 }
 
+func (i *Info) Prerelease() string {
+	return i.prerelease
+}
+
+func (i *Info) Commit() string {
+	return i.commit
+}
+
+func (i *Info) CommitTime() time.Time {
+	return i.commitTime
+}
+
+func (i *Info) BuildTime() time.Time {
+	return i.buildTime
+}
+
+func (i *Info) Dirty() bool {
+	return i.dirty
+}
+
+// VersionString returns a semantic version, including the prerelease and the abbreviated
+// commit as build metadata if known, e.g. '1.2.3-rc.1+a1b2c3d.dirty'.
 func (i *Info) VersionString() string {
-	return fmt.Sprintf("%d.%d.%d", i.majorVersion, i.minorVersion, i.fixVersion)
+	v := fmt.Sprintf("%d.%d.%d", i.majorVersion, i.minorVersion, i.fixVersion)
+	if i.prerelease != "" {
+		v = v + "-" + i.prerelease
+	}
+
+	meta := make([]string, 0, 2)
+	if i.commit != "" {
+		commit := i.commit
+		if len(commit) > shortCommitLength {
+			commit = commit[:shortCommitLength]
+		}
+		meta = append(meta, commit)
+	}
+	if i.dirty {
+		meta = append(meta, "dirty")
+	}
+	if len(meta) > 0 {
+		v = v + "+" + strings.Join(meta, ".")
+	}
+	return v
 }
 
 func (i *Info) UserAgentString() string {
-	return fmt.Sprintf("%s %d.%d.%d", i.shortName, i.majorVersion, i.minorVersion, i.fixVersion)
+	return fmt.Sprintf("%s %s", i.shortName, i.VersionString())
 }
 
 func (i *Info) ServerString() string {
-	return fmt.Sprintf("%s %d.%d.%d", i.shortName, i.majorVersion, i.minorVersion, i.fixVersion)
+	return fmt.Sprintf("%s %s", i.shortName, i.VersionString())
 }
@@ -0,0 +1,446 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+// Settings are resolved from several layers, each one overriding the ones before:
+// built-in defaults < config file < environment variables < command line flags.
+// All values are kept as strings, using flat keys like 'endpoint' or 'credentials.token'.
+
+const (
+	SourceDefault Source = iota // built-in defaults
+	SourceFile                  // the config file
+	SourceEnv                   // APIKIT_* environment variables
+	SourceFlag                  // command line flags
+
+	// EnvPrefix is the prefix of all environment variables that override settings
+	EnvPrefix = "APIKIT_"
+
+	// DialSettings keys
+	KeyEndpoint      = "endpoint"
+	KeyUserAgent     = "user_agent"
+	KeyScopes        = "scopes"
+	KeyDefaultScopes = "default_scopes"
+	KeyProjectID     = "credentials.project_id"
+	KeyClientID      = "credentials.client_id"
+	KeyClientSecret  = "credentials.client_secret"
+	KeyToken         = "credentials.token"
+	KeyStatus        = "credentials.status"
+	KeyExpires       = "credentials.expires"
+	// KeyOptionPrefix is the prefix of all DialSettings.Options, e.g. 'options.APIKey'
+	KeyOptionPrefix = "options."
+
+	// server keys
+	KeyPort          = "server.port"
+	KeyShutdownDelay = "server.shutdown_delay"
+	KeyReadTimeout   = "server.read_timeout"
+	KeyWriteTimeout  = "server.write_timeout"
+	KeyLogLevel      = "server.log_level"
+
+	// server defaults
+	DefaultShutdownDelay = 30 * time.Second
+)
+
+type (
+	// Source identifies the layer a setting was resolved from
+	Source int
+
+	// Setting is an effective value and where it came from
+	Setting struct {
+		Key    string
+		Value  string
+		Source Source
+	}
+
+	// Layers holds the raw values of all layers
+	Layers struct {
+		layers [SourceFlag + 1]map[string]string
+		mu     sync.RWMutex
+	}
+
+	// ServerSettings are the resolved settings of the service listener
+	ServerSettings struct {
+		Port          string // empty unless explicitly configured
+		ShutdownDelay time.Duration
+		ReadTimeout   time.Duration
+		WriteTimeout  time.Duration
+		LogLevel      string // empty unless explicitly configured
+	}
+)
+
+var (
+	// all keys except options
+	settingKeys = []string{
+		KeyEndpoint, KeyUserAgent, KeyScopes, KeyDefaultScopes,
+		KeyProjectID, KeyClientID, KeyClientSecret, KeyToken, KeyStatus, KeyExpires,
+		KeyPort, KeyShutdownDelay, KeyReadTimeout, KeyWriteTimeout, KeyLogLevel,
+	}
+
+	// keys of the credentials, they are stored encrypted, see WriteCredentials
+	credentialKeys = []string{KeyProjectID, KeyClientID, KeyClientSecret, KeyToken, KeyStatus, KeyExpires}
+	// keys of the ServerSettings
+	serverKeys = []string{KeyPort, KeyShutdownDelay, KeyReadTimeout, KeyWriteTimeout, KeyLogLevel}
+
+	// environment variables that predate the APIKIT_* variables
+	legacyEnv = map[string]string{
+		KeyEndpoint: APIEndpointENV,
+		KeyPort:     PortENV,
+	}
+
+	// values set from command line flags
+	flagValues = make(map[string]string)
+	fmu        sync.RWMutex // used to protect the above
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	}
+	return "unknown"
+}
+
+// NewLayers returns empty layers
+func NewLayers() *Layers {
+	l := &Layers{}
+	for i := range l.layers {
+		l.layers[i] = make(map[string]string)
+	}
+	return l
+}
+
+// Set sets the value of a key in one layer
+func (l *Layers) Set(src Source, key, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.layers[src][key] = value
+}
+
+// Unset removes a key from one layer
+func (l *Layers) Unset(src Source, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.layers[src], key)
+}
+
+// Replace replaces all values of one layer
+func (l *Layers) Replace(src Source, values map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.layers[src] = make(map[string]string)
+	for k, v := range values {
+		l.layers[src][k] = v
+	}
+}
+
+// Layer returns the values of one layer
+func (l *Layers) Layer(src Source) map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	values := make(map[string]string)
+	for k, v := range l.layers[src] {
+		values[k] = v
+	}
+	return values
+}
+
+// Lookup returns the effective value of a key, i.e. the value from the highest layer that has one
+func (l *Layers) Lookup(key string) (Setting, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for src := SourceFlag; src >= SourceDefault; src-- {
+		if v, ok := l.layers[src][key]; ok {
+			return Setting{Key: key, Value: v, Source: src}, true
+		}
+	}
+	return Setting{Key: key}, false
+}
+
+// Settings returns all effective settings, sorted by key
+func (l *Layers) Settings() []Setting {
+	l.mu.RLock()
+	keys := make(map[string]bool)
+	for _, layer := range l.layers {
+		for k := range layer {
+			keys[k] = true
+		}
+	}
+	l.mu.RUnlock()
+
+	all := make([]Setting, 0, len(keys))
+	for k := range keys {
+		s, _ := l.Lookup(k)
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+
+	return all
+}
+
+// Values returns all effective values
+func (l *Layers) Values() map[string]string {
+	values := make(map[string]string)
+	for _, s := range l.Settings() {
+		values[s.Key] = s.Value
+	}
+	return values
+}
+
+// DialSettings builds the effective DialSettings
+func (l *Layers) DialSettings() *settings.DialSettings {
+	return UnflattenDialSettings(l.Values())
+}
+
+// Server builds the effective ServerSettings
+func (l *Layers) Server() ServerSettings {
+	s := ServerSettings{
+		ShutdownDelay: DefaultShutdownDelay,
+	}
+	if v, ok := l.Lookup(KeyPort); ok {
+		s.Port = v.Value
+	}
+	if v, ok := l.Lookup(KeyShutdownDelay); ok {
+		s.ShutdownDelay = parseDuration(v.Value, s.ShutdownDelay)
+	}
+	if v, ok := l.Lookup(KeyReadTimeout); ok {
+		s.ReadTimeout = parseDuration(v.Value, 0)
+	}
+	if v, ok := l.Lookup(KeyWriteTimeout); ok {
+		s.WriteTimeout = parseDuration(v.Value, 0)
+	}
+	if v, ok := l.Lookup(KeyLogLevel); ok {
+		s.LogLevel = v.Value
+	}
+	return s
+}
+
+// FlattenDialSettings converts DialSettings into flat key/value pairs
+func FlattenDialSettings(ds *settings.DialSettings) map[string]string {
+	values := make(map[string]string)
+	if ds == nil {
+		return values
+	}
+
+	putString(values, KeyEndpoint, ds.Endpoint)
+	putString(values, KeyUserAgent, ds.UserAgent)
+	putString(values, KeyScopes, strings.Join(ds.Scopes, ","))
+	putString(values, KeyDefaultScopes, strings.Join(ds.DefaultScopes, ","))
+
+	if ds.Credentials != nil {
+		putString(values, KeyProjectID, ds.Credentials.ProjectID)
+		putString(values, KeyClientID, ds.Credentials.ClientID)
+		putString(values, KeyClientSecret, ds.Credentials.ClientSecret)
+		putString(values, KeyToken, ds.Credentials.Token)
+		if ds.Credentials.Status != 0 {
+			values[KeyStatus] = strconv.Itoa(int(ds.Credentials.Status))
+		}
+		if ds.Credentials.Expires != 0 {
+			values[KeyExpires] = strconv.FormatInt(ds.Credentials.Expires, 10)
+		}
+	}
+
+	for k, v := range ds.Options {
+		values[KeyOptionPrefix+k] = v
+	}
+
+	return values
+}
+
+// UnflattenDialSettings is the reverse of FlattenDialSettings. Values that can't be parsed are ignored.
+func UnflattenDialSettings(values map[string]string) *settings.DialSettings {
+	ds := &settings.DialSettings{
+		Endpoint:      values[KeyEndpoint],
+		UserAgent:     values[KeyUserAgent],
+		Scopes:        splitList(values[KeyScopes]),
+		DefaultScopes: splitList(values[KeyDefaultScopes]),
+		Credentials: &settings.Credentials{ // always add this to avoid NPEs further down
+			ProjectID:    values[KeyProjectID],
+			ClientID:     values[KeyClientID],
+			ClientSecret: values[KeyClientSecret],
+			Token:        values[KeyToken],
+		},
+	}
+
+	if status, err := strconv.Atoi(values[KeyStatus]); err == nil {
+		ds.Credentials.Status = settings.State(status)
+	}
+	if expires, err := strconv.ParseInt(values[KeyExpires], 10, 64); err == nil {
+		ds.Credentials.Expires = expires
+	}
+
+	for k, v := range values {
+		if strings.HasPrefix(k, KeyOptionPrefix) {
+			ds.SetOption(strings.TrimPrefix(k, KeyOptionPrefix), v)
+		}
+	}
+
+	return ds
+}
+
+// EnvName returns the environment variable that overrides a key, e.g. 'APIKIT_CREDENTIALS_TOKEN'.
+// Options keep their case, e.g. 'APIKIT_OPTIONS_APIKey'.
+func EnvName(key string) string {
+	if strings.HasPrefix(key, KeyOptionPrefix) {
+		return EnvPrefix + "OPTIONS_" + strings.TrimPrefix(key, KeyOptionPrefix)
+	}
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// EnvValues reads all settings from the environment. APIKIT_* variables take precedence over legacy ones.
+func EnvValues() map[string]string {
+	values := make(map[string]string)
+
+	for key, env := range legacyEnv {
+		if v := os.Getenv(env); v != "" {
+			values[key] = v
+		}
+	}
+	for _, key := range settingKeys {
+		if v := os.Getenv(EnvName(key)); v != "" {
+			values[key] = v
+		}
+	}
+
+	prefix := EnvPrefix + "OPTIONS_"
+	for _, e := range os.Environ() {
+		k, v, _ := strings.Cut(e, "=")
+		if strings.HasPrefix(k, prefix) && len(k) > len(prefix) && v != "" {
+			values[KeyOptionPrefix+strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+
+	return values
+}
+
+// SetFlag overrides a setting from the command line. Flags take precedence over all other layers.
+func SetFlag(key, value string) {
+	fmu.Lock()
+	flagValues[key] = value
+	fmu.Unlock()
+
+	reset()
+}
+
+// FlagValues returns all settings set with SetFlag
+func FlagValues() map[string]string {
+	fmu.RLock()
+	defer fmu.RUnlock()
+
+	values := make(map[string]string)
+	for k, v := range flagValues {
+		values[k] = v
+	}
+	return values
+}
+
+// ResetFlags removes all settings set with SetFlag
+func ResetFlags() {
+	fmu.Lock()
+	flagValues = make(map[string]string)
+	fmu.Unlock()
+
+	reset()
+}
+
+// Lookup returns the effective value of a setting and where it came from.
+func Lookup(key string) (Setting, bool) {
+	return currentLayers().Lookup(key)
+}
+
+// Resolved returns all effective settings and where they came from, sorted by key.
+func Resolved() []Setting {
+	return currentLayers().Settings()
+}
+
+// DefaultValues returns the built-in defaults of all settings.
+func DefaultValues() map[string]string {
+	return currentLayers().Layer(SourceDefault)
+}
+
+// Server returns the effective settings of the service listener.
+func Server() ServerSettings {
+	return currentLayers().Server()
+}
+
+// currentLayers returns the layers of the config provider, if it has any
+func currentLayers() *Layers {
+	if lp, ok := config_.(interface{ Layers() *Layers }); ok {
+		return lp.Layers()
+	}
+
+	// defaults, env and flags only
+	l := NewLayers()
+	if config_ != nil {
+		l.Replace(SourceDefault, FlattenDialSettings(config_.Settings()))
+	}
+	l.Replace(SourceEnv, EnvValues())
+	l.Replace(SourceFlag, FlagValues())
+
+	return l
+}
+
+// reset forces the config provider to resolve its settings again
+func reset() {
+	if r, ok := config_.(interface{ reset() }); ok {
+		r.reset()
+	}
+}
+
+func isServerKey(key string) bool {
+	for _, k := range serverKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func putString(values map[string]string, key, value string) {
+	if value != "" {
+		values[key] = value
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseDuration accepts Go durations like '30s' or plain seconds
+func parseDuration(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	return def
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/helpers"
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func TestFlattenDialSettings(t *testing.T) {
+	ds := &settings.DialSettings{
+		Endpoint:      "http://localhost:9090",
+		UserAgent:     "test 1.0.0",
+		Scopes:        []string{"api:read", "api:write"},
+		DefaultScopes: []string{"api:read"},
+		Credentials: &settings.Credentials{
+			ProjectID: "project",
+			ClientID:  "client",
+			Token:     "token",
+			Status:    settings.StateAuthorized,
+			Expires:   42,
+		},
+		Options: map[string]string{"APIKey": "key"},
+	}
+
+	values := FlattenDialSettings(ds)
+	assert.Equal(t, "api:read,api:write", values[KeyScopes])
+	assert.Equal(t, strconv.Itoa(int(settings.StateAuthorized)), values[KeyStatus])
+	assert.Equal(t, "key", values[KeyOptionPrefix+"APIKey"])
+
+	assert.Equal(t, ds, UnflattenDialSettings(values))
+}
+
+func TestLayerPrecedence(t *testing.T) {
+	l := NewLayers()
+	l.Set(SourceDefault, KeyEndpoint, "default")
+	l.Set(SourceFile, KeyEndpoint, "file")
+	l.Set(SourceDefault, KeyUserAgent, "agent")
+
+	s, ok := l.Lookup(KeyEndpoint)
+	assert.True(t, ok)
+	assert.Equal(t, "file", s.Value)
+	assert.Equal(t, SourceFile, s.Source)
+
+	l.Set(SourceFlag, KeyEndpoint, "flag")
+	l.Set(SourceEnv, KeyEndpoint, "env")
+	s, _ = l.Lookup(KeyEndpoint)
+	assert.Equal(t, "flag", s.Value)
+	assert.Equal(t, "flag", s.Source.String())
+
+	l.Unset(SourceFlag, KeyEndpoint)
+	s, _ = l.Lookup(KeyEndpoint)
+	assert.Equal(t, SourceEnv, s.Source)
+
+	s, _ = l.Lookup(KeyUserAgent)
+	assert.Equal(t, SourceDefault, s.Source)
+
+	_, ok = l.Lookup(KeyToken)
+	assert.False(t, ok)
+
+	assert.Equal(t, 2, len(l.Settings()))
+}
+
+func TestLocalConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+
+	// the config file
+	assert.NoError(t, helpers.WriteDialSettings(&settings.DialSettings{
+		Endpoint:  "http://file:8080",
+		UserAgent: "file-agent",
+		Credentials: &settings.Credentials{
+			ClientID: "file-client",
+		},
+	}, filepath.Join(dir, DefaultConfigName)))
+
+	// the environment
+	t.Setenv(EnvName(KeyUserAgent), "env-agent")
+	t.Setenv(EnvName(KeyReadTimeout), "5s")
+	t.Setenv(EnvName(KeyOptionPrefix+"Region"), "eu")
+
+	// loading the settings migrates the credentials
+	setupSecrets(t)
+	SetConfigLocation(dir)
+
+	// a flag
+	SetFlag(KeyEndpoint, "http://flag:8080")
+	defer ResetFlags()
+
+	ds := GetConfig().Settings()
+	assert.Equal(t, "http://flag:8080", ds.Endpoint)
+	assert.Equal(t, "env-agent", ds.UserAgent)
+	assert.Equal(t, "file-client", ds.Credentials.ClientID)
+	assert.Equal(t, "eu", ds.GetOption("Region"))
+	assert.NotEmpty(t, ds.GetScopes()) // from the defaults
+
+	s, _ := Lookup(KeyEndpoint)
+	assert.Equal(t, SourceFlag, s.Source)
+	s, _ = Lookup(KeyUserAgent)
+	assert.Equal(t, SourceEnv, s.Source)
+	s, _ = Lookup(KeyClientID)
+	assert.Equal(t, SourceFile, s.Source)
+	s, _ = Lookup(KeyDefaultScopes)
+	assert.Equal(t, SourceDefault, s.Source)
+
+	srv := Server()
+	assert.Equal(t, 5*time.Second, srv.ReadTimeout)
+	assert.Equal(t, DefaultShutdownDelay, srv.ShutdownDelay)
+	assert.Empty(t, srv.Port)
+}
@@ -3,17 +3,17 @@ package config
 import (
 	"log"
 	"os"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
 
-	"github.com/txsvc/cloudlib/helpers"
 	"github.com/txsvc/cloudlib/settings"
 	"github.com/txsvc/stdlib/v2"
 
 	"github.com/txsvc/apikit/auth"
 )
 
-// the below version numbers should match the git release tags,
-// i.e. there should be a version 'v0.1.0' on branch main !
+// the below version numbers are used if the build has no version, see InfoFromBuild().
+// They should match the git release tags, i.e. there should be a version 'v0.1.0' on branch main !
 const (
 	majorVersion = 0
 	minorVersion = 1
@@ -30,8 +30,13 @@ type (
 		// path to configuration settings
 		rootDir string // the current working dir
 		confDir string // the fully qualified path to the conf dir
-		// cached settings
-		ds *settings.DialSettings
+		// all layers of settings
+		layers atomic.Pointer[Layers]
+		// cached settings, swapped atomically on reload
+		ds atomic.Pointer[settings.DialSettings]
+		// the error of loading the cached settings, if any
+		err error
+		mu  sync.Mutex // serializes loading the settings, protects err
 	}
 )
 
@@ -43,66 +48,164 @@ func NewLocalConfigProvider() ConfigProvider {
 		log.Fatal(err)
 	}
 
+	info := defaultInfo()
+
 	c := &localConfig{
 		rootDir: dir,
 		confDir: "",
-		info: &Info{
-			name:         "appkit",
-			shortName:    "appkit",
-			copyright:    "Copyright 2022, transformative.services, https://txs.vc",
-			about:        "about appkit",
-			majorVersion: majorVersion,
-			minorVersion: minorVersion,
-			fixVersion:   fixVersion,
-		},
+		info:    &info,
 	}
 
+	c.layers.Store(NewLayers())
+
 	return c
 }
 
+// defaultInfo returns the app info used by the built-in config providers
+func defaultInfo() Info {
+	return InfoFromBuild(
+		"appkit",
+		"appkit",
+		"Copyright 2022, transformative.services, https://txs.vc",
+		"about appkit",
+		majorVersion,
+		minorVersion,
+		fixVersion,
+	)
+}
+
 func (c *localConfig) Info() *Info {
 	return c.info
 }
 
 // ConfigLocation returns the config location that was set using SetConfigLocation().
-// If no location is defined, GetConfigLocation looks for ENV['CONFIG_LOCATION'] or
-// returns DefaultConfigLocation() if no environment variable was set.
+// If no location is defined, ConfigLocation looks for ENV['CONFIG_LOCATION'] or returns
+// the user's config directory, see UserConfigLocation(). Project-local config files in
+// DefaultConfigLocation are used until they are moved, see MigrateLocations().
 func (c *localConfig) ConfigLocation() string {
-	if len(c.confDir) == 0 {
-		return stdlib.GetString(ConfigDirLocationENV, DefaultConfigLocation)
+	if len(c.confDir) > 0 {
+		return c.confDir
+	}
+	if loc := stdlib.GetString(ConfigDirLocationENV, ""); loc != "" {
+		return loc
 	}
-	return c.confDir
+	return searchLocation(DefaultConfigLocation, UserConfigLocation(shortNameOf(c.info)))
 }
 
 func (c *localConfig) SetConfigLocation(loc string) {
 	c.confDir = loc
-	if c.ds != nil {
-		c.ds = nil // force a reload the next time GetSettings() is called ...
-	}
+	c.reset() // force a reload the next time Settings() is called ...
 }
 
+// Settings resolves the settings from the built-in defaults, the config file (if there is one),
+// APIKIT_* environment variables and command line flags, in that order of precedence.
 func (c *localConfig) Settings() *settings.DialSettings {
-	if c.ds != nil {
-		return c.ds
+	if ds := c.ds.Load(); ds != nil {
+		return ds
 	}
 
-	// try to load the dial settings
-	pathToFile := filepath.Join(c.ConfigLocation(), DefaultConfigName)
-	cfg, err := helpers.ReadDialSettings(pathToFile)
-	if err != nil {
-		cfg = c.defaultSettings()
-		// save to the default location
-		if err = helpers.WriteDialSettings(cfg, pathToFile); err != nil {
-			log.Fatal(err)
-		}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// someone else might have loaded them in the meantime
+	if ds := c.ds.Load(); ds != nil {
+		return ds
 	}
 
-	// patch values from ENV, if available
-	cfg.Endpoint = stdlib.GetString(APIEndpointENV, cfg.Endpoint)
+	// the settings are usable even if some files could not be read, see SettingsError()
+	l, err := c.resolve()
+	c.err = err
 
 	// make it available for future calls
-	c.ds = cfg
-	return c.ds
+	ds := l.DialSettings()
+	c.layers.Store(l)
+	c.ds.Store(ds)
+
+	return ds
+}
+
+// settingsError returns the error of loading the current settings
+func (c *localConfig) settingsError() error {
+	c.Settings() // make sure the settings are loaded
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Validate checks the effective settings and reports files that could not be read
+func (c *localConfig) Validate() error {
+	err := ValidateSettings(c.Settings())
+
+	ferr := c.settingsError()
+	if ferr == nil {
+		return err
+	}
+	errs, _ := err.(ValidationErrors)
+	return append(ValidationErrors{{Field: "file", Message: ferr.Error()}}, errs...)
+}
+
+// Layers returns the layers the settings are resolved from
+func (c *localConfig) Layers() *Layers {
+	c.Settings() // make sure the layers are loaded
+	return c.layers.Load()
+}
+
+// reload resolves the settings again and swaps them, unless the new settings are invalid
+func (c *localConfig) reload() (*Layers, *Layers, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, err := c.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+	ds := l.DialSettings()
+	if err := ValidateSettings(ds); err != nil {
+		return nil, nil, err
+	}
+
+	old := c.layers.Swap(l)
+	c.ds.Store(ds)
+	c.err = nil
+
+	return old, l, nil
+}
+
+func (c *localConfig) reset() {
+	c.ds.Store(nil)
+}
+
+// resolve reads all layers. If the config file can't be read, it is ignored. If only the
+// credentials can't be decrypted, the rest of the config file is used. If the active profile
+// can't be used, no config file is read at all.
+func (c *localConfig) resolve() (*Layers, error) {
+	l := NewLayers()
+	l.Replace(SourceDefault, FlattenDialSettings(c.defaultSettings()))
+
+	// load the dial settings, older files are migrated in memory, see MigrateConfig()
+	err := c.resolveFile(l)
+
+	l.Replace(SourceEnv, EnvValues())
+	l.Replace(SourceFlag, FlagValues())
+
+	return l, err
+}
+
+// resolveFile adds the settings of the active profile's config file
+func (c *localConfig) resolveFile(l *Layers) error {
+	root := c.ConfigLocation()
+	profile, err := currentProfileIn(root)
+	if err != nil {
+		return err // never fall back to the settings of another profile
+	}
+
+	values, err := readStoredValues(root, profile)
+	if values != nil {
+		l.Replace(SourceFile, values)
+	}
+	return err
 }
 
 func (c *localConfig) defaultSettings() *settings.DialSettings {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,6 +19,10 @@ func TestInitLocalProvider(t *testing.T) {
 }
 
 func TestConfigLocation(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(XDGConfigHomeENV, home)
+	t.Setenv(ConfigDirLocationENV, "")
+
 	SetProvider(NewLocalConfigProvider())
 
 	cfg := GetConfig()
@@ -25,7 +30,10 @@ func TestConfigLocation(t *testing.T) {
 
 	path := cfg.ConfigLocation()
 	assert.NotEmpty(t, path)
-	assert.Equal(t, DefaultConfigLocation, path)
+	assert.Equal(t, filepath.Join(home, cfg.Info().ShortName()), path)
+
+	t.Setenv(ConfigDirLocationENV, "./.config")
+	assert.Equal(t, DefaultConfigLocation, cfg.ConfigLocation())
 
 	cfg.SetConfigLocation("$HOME/.config")
 	assert.Equal(t, "$HOME/.config", cfg.ConfigLocation())
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Unless set explicitly, the config and the secrets live in the user's config directory, i.e.
+// $XDG_CONFIG_HOME/<shortname> or ~/.config/<shortname>. Project-local files in './.config' and
+// './.secrets' are still used as long as they exist: resolving the locations never copies or
+// moves files. MigrateLocations() moves both to the user's config directory in one step.
+//
+// Note that this changes where new files are created: without project-local files, services and
+// CLIs that used to create './.config' now use the user's config directory.
+
+const (
+	// XDGConfigHomeENV is the base directory of user specific configuration files
+	XDGConfigHomeENV = "XDG_CONFIG_HOME"
+
+	// secretsDirName is the secrets location in the user's config directory
+	secretsDirName = "secrets"
+	// migratedMarker is left in project-local directories after they were copied
+	migratedMarker = ".migrated"
+	// used for all directories that might contain credentials
+	configDirPerm fs.FileMode = 0700
+)
+
+// UserConfigLocation returns $XDG_CONFIG_HOME/<shortName>, or ~/.config/<shortName> if the variable
+// is not set. If the home directory is unknown, "" is returned.
+func UserConfigLocation(shortName string) string {
+	if base := os.Getenv(XDGConfigHomeENV); filepath.IsAbs(base) {
+		return filepath.Join(base, shortName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".config", shortName)
+}
+
+// searchLocation returns the project-local directory if it has files that were not moved to
+// the user's directory yet, or the user's directory otherwise.
+func searchLocation(local, user string) string {
+	if user == "" || hasLocalFiles(local) {
+		return local
+	}
+	return user
+}
+
+// MigrateLocations moves the project-local config and secrets to the user's config directory,
+// if they are in use, and returns the steps taken. With dryRun, only the steps are returned. Both
+// destinations are checked before anything is copied, existing files are never overwritten.
+func MigrateLocations(dryRun bool) ([]string, error) {
+	if err := checkWritable(); err != nil {
+		return nil, err
+	}
+	user := UserConfigLocation(shortNameOf(GetConfig().Info()))
+	if user == "" {
+		return nil, nil // nowhere to move to
+	}
+
+	type move struct{ from, to string }
+	moves := make([]move, 0, 2)
+	if GetConfig().ConfigLocation() == DefaultConfigLocation && hasLocalFiles(DefaultConfigLocation) {
+		moves = append(moves, move{DefaultConfigLocation, user})
+	}
+	if SecretsLocation() == DefaultCredentialsLocation && hasLocalFiles(DefaultCredentialsLocation) {
+		moves = append(moves, move{DefaultCredentialsLocation, filepath.Join(user, secretsDirName)})
+	}
+
+	steps := make([]string, 0, len(moves))
+	for _, m := range moves {
+		if err := checkDestination(m.to); err != nil {
+			return nil, err
+		}
+		steps = append(steps, fmt.Sprintf("move '%s' to '%s'", m.from, m.to))
+	}
+	if dryRun || len(moves) == 0 {
+		return steps, nil
+	}
+
+	for _, m := range moves {
+		if err := copyLocation(m.from, m.to); err != nil {
+			return nil, err
+		}
+	}
+
+	reset() // resolve the settings again from the new location
+	return steps, nil
+}
+
+// checkDestination returns an error if dir already has files, except the secrets location
+func checkDestination(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() != secretsDirName {
+			return fmt.Errorf("%w: '%s' is not empty", ErrInitializingConfiguration, dir)
+		}
+	}
+	return nil
+}
+
+// hasLocalFiles returns true if dir exists and was not copied yet
+func hasLocalFiles(dir string) bool {
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, migratedMarker))
+	return os.IsNotExist(err)
+}
+
+// copyLocation copies all files from one directory to another and marks the original as moved.
+// Existing files in the destination are never overwritten.
+func copyLocation(from, to string) error {
+	err := filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, configDirPerm)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, configFilePerm)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("copied to %s, the files in this directory are no longer used\n", to)
+	return os.WriteFile(filepath.Join(from, migratedMarker), []byte(note), configFilePerm)
+}
+
+// shortNameOf returns a name that is safe to use as a directory
+func shortNameOf(info *Info) string {
+	if info == nil || info.ShortName() == "" {
+		return "apikit"
+	}
+	return strings.ReplaceAll(info.ShortName(), string(filepath.Separator), "_")
+}
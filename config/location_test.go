@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserConfigLocation(t *testing.T) {
+	t.Setenv(XDGConfigHomeENV, "/xdg")
+	assert.Equal(t, filepath.Join("/xdg", "apikit"), UserConfigLocation("apikit"))
+
+	// relative paths are not valid, see the XDG base directory specification
+	t.Setenv(XDGConfigHomeENV, "xdg")
+	home, err := os.UserHomeDir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".config", "apikit"), UserConfigLocation("apikit"))
+}
+
+func TestSearchLocation(t *testing.T) {
+	local := filepath.Join(t.TempDir(), ".config")
+	user := filepath.Join(t.TempDir(), "apikit")
+
+	// no local files
+	assert.Equal(t, user, searchLocation(local, user))
+	assert.Equal(t, local, searchLocation(local, ""))
+
+	// local files are used, but not copied
+	assert.NoError(t, os.MkdirAll(local, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(local, DefaultConfigName), []byte(`{}`), configFilePerm))
+	assert.Equal(t, local, searchLocation(local, user))
+	_, err := os.Stat(user)
+	assert.True(t, os.IsNotExist(err))
+
+	// until they were moved
+	assert.NoError(t, copyLocation(local, user))
+	assert.Equal(t, user, searchLocation(local, user))
+	assert.False(t, hasLocalFiles(local))
+}
+
+func TestMigrateLocations(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(XDGConfigHomeENV, home)
+	t.Setenv(ConfigDirLocationENV, "")
+	t.Setenv(SecretsLocationENV, "")
+	chdir(t, t.TempDir())
+
+	SetProvider(NewLocalConfigProvider())
+	SetSecretsLocation("")
+	user := filepath.Join(home, shortNameOf(GetConfig().Info()))
+
+	// the secrets exist before the config, they are moved together anyway
+	assert.NoError(t, os.MkdirAll(filepath.Join(DefaultConfigLocation, profilesDir, "staging"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(DefaultConfigLocation, DefaultConfigName), []byte(`{"endpoint": "https://api.example.com"}`), configFilePerm))
+	assert.NoError(t, os.MkdirAll(DefaultCredentialsLocation, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(DefaultCredentialsLocation, DefaultCredentialsName), []byte(`x`), configFilePerm))
+	assert.NoError(t, os.MkdirAll(filepath.Join(user, secretsDirName), os.ModePerm))
+
+	// resolving the locations has no side effects
+	assert.Equal(t, DefaultConfigLocation, GetConfig().ConfigLocation())
+	assert.Equal(t, DefaultCredentialsLocation, SecretsLocation())
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+
+	steps, err := MigrateLocations(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(steps))
+	assert.True(t, hasLocalFiles(DefaultConfigLocation))
+
+	steps, err = MigrateLocations(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(steps))
+
+	assert.Equal(t, user, GetConfig().ConfigLocation())
+	assert.Equal(t, filepath.Join(user, secretsDirName), SecretsLocation())
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+	_, err = os.Stat(filepath.Join(user, profilesDir, "staging"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(user, secretsDirName, DefaultCredentialsName))
+	assert.NoError(t, err)
+
+	// nothing left to do
+	steps, err = MigrateLocations(false)
+	assert.NoError(t, err)
+	assert.Empty(t, steps)
+}
+
+func TestMigrateLocationsNotEmpty(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(XDGConfigHomeENV, home)
+	t.Setenv(ConfigDirLocationENV, "")
+	t.Setenv(SecretsLocationENV, "")
+	chdir(t, t.TempDir())
+
+	SetProvider(NewLocalConfigProvider())
+	SetSecretsLocation("")
+	user := filepath.Join(home, shortNameOf(GetConfig().Info()))
+
+	assert.NoError(t, os.MkdirAll(DefaultConfigLocation, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(DefaultConfigLocation, DefaultConfigName), []byte(`{}`), configFilePerm))
+	assert.NoError(t, os.MkdirAll(DefaultCredentialsLocation, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(DefaultCredentialsLocation, DefaultCredentialsName), []byte(`x`), configFilePerm))
+
+	// the user's directory has a config of its own, nothing is moved
+	assert.NoError(t, os.MkdirAll(user, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(user, DefaultConfigName), []byte(`{}`), configFilePerm))
+
+	_, err := MigrateLocations(false)
+	assert.ErrorIs(t, err, ErrInitializingConfiguration)
+	assert.True(t, hasLocalFiles(DefaultConfigLocation))
+	assert.True(t, hasLocalFiles(DefaultCredentialsLocation))
+	_, err = os.Stat(filepath.Join(user, secretsDirName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
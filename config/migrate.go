@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Config files carry a schema version. Files written by older versions are migrated in memory
+// when the settings are loaded, one version at a time, so that they keep working after upgrades.
+// The file itself is only rewritten by MigrateConfig(), after a backup of the original file was
+// made. The backup never contains credentials. Files without a version are version 1.
+
+const (
+	// the version of files without an explicit version
+	initialSchemaVersion = 1
+)
+
+type (
+	// MigrationFunc migrates the flat values of a config file, see FlattenDialSettings. It runs
+	// whenever an older file is loaded and must not have side effects.
+	MigrationFunc func(values map[string]string) error
+
+	migration struct {
+		description string
+		fn          MigrationFunc                                        // migrates the values, in memory
+		persist     func(profile string, values map[string]string) error // runs before fn, only when the file is rewritten
+	}
+)
+
+var (
+	// ErrUnsupportedSchema indicates that the config file was written by a newer version
+	ErrUnsupportedSchema = errors.New("unsupported config schema version")
+
+	// migrations by the version they migrate from
+	migrations = map[int]*migration{
+		1: {
+			description: "move the credentials into the encrypted secrets location",
+			persist:     moveCredentials, // plaintext credentials are still read until then
+		},
+	}
+	mmu sync.RWMutex // used to protect the above
+)
+
+// RegisterMigration adds a migration from one schema version to the next. The current schema
+// version is the one after the highest registered migration.
+func RegisterMigration(from int, description string, fn MigrationFunc) {
+	mmu.Lock()
+	defer mmu.Unlock()
+
+	migrations[from] = &migration{
+		description: description,
+		fn:          fn,
+	}
+}
+
+// CurrentSchemaVersion returns the schema version of new config files.
+func CurrentSchemaVersion() int {
+	mmu.RLock()
+	defer mmu.RUnlock()
+
+	version := initialSchemaVersion
+	for from := range migrations {
+		if from >= version {
+			version = from + 1
+		}
+	}
+	return version
+}
+
+// MigrateConfig migrates the config file of the active profile to the current schema version
+// and returns the steps taken. With dryRun, only the steps are returned.
+func MigrateConfig(dryRun bool) ([]string, error) {
+	if err := checkWritable(); err != nil {
+		return nil, err
+	}
+	profile, err := CurrentProfile()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := migrateIn(GetConfig().ConfigLocation(), profile, dryRun)
+	if err == nil && len(steps) > 0 && !dryRun {
+		reset()
+	}
+	return steps, err
+}
+
+// BackupFile returns the name of the backup made before migrating a file from a version.
+func BackupFile(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+func migrateIn(root, profile string, dryRun bool) ([]string, error) {
+	path := ConfigFileIn(profileDir(root, profile))
+	f, err := readFileSettings(path)
+	if os.IsNotExist(err) {
+		return nil, nil // nothing to migrate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version := f.schemaVersion()
+	todo, err := migrationsFrom(version)
+	if err != nil {
+		return nil, err
+	}
+	if len(todo) == 0 {
+		return nil, nil // up to date
+	}
+
+	steps := make([]string, 0, len(todo))
+	for i, m := range todo {
+		steps = append(steps, fmt.Sprintf("v%d -> v%d: %s", version+i, version+i+1, m.description))
+	}
+
+	if dryRun {
+		return steps, nil
+	}
+
+	// keep the original file, without its credentials
+	backup := *f
+	if backup.Credentials != nil {
+		backup.Credentials = &fileCredentials{
+			ProjectID: f.Credentials.ProjectID,
+			ClientID:  f.Credentials.ClientID,
+		}
+	}
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeSettings(format, &backup)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(BackupFile(path, version), data, configFilePerm); err != nil {
+		return nil, err
+	}
+
+	values := f.values()
+	for i, m := range todo {
+		if m.persist != nil {
+			if err := m.persist(profile, values); err != nil {
+				return nil, fmt.Errorf("migrating from version %d: %w", version+i, err)
+			}
+		}
+		if err := migrateValues(version+i, todo[i:i+1], values); err != nil {
+			return nil, err
+		}
+	}
+
+	// migrations must not leave credentials in the config file, see moveCredentials
+	migrated := newFileValues(values)
+	migrated.Credentials = nil
+	migrated.Version = version + len(todo)
+	if err := writeFileSettings(migrated, path); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// migrationsFrom returns the migrations from a schema version to the current one
+func migrationsFrom(version int) ([]*migration, error) {
+	target := CurrentSchemaVersion()
+	if version > target {
+		return nil, fmt.Errorf("%w: %d, expected %d or lower", ErrUnsupportedSchema, version, target)
+	}
+
+	mmu.RLock()
+	defer mmu.RUnlock()
+
+	todo := make([]*migration, 0, target-version)
+	for v := version; v < target; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("%w: no migration from version %d", ErrUnsupportedSchema, v)
+		}
+		todo = append(todo, m)
+	}
+	return todo, nil
+}
+
+// migrateValues runs the migrations on the values of a file with the given schema version
+func migrateValues(version int, todo []*migration, values map[string]string) error {
+	for i, m := range todo {
+		if m.fn == nil {
+			continue
+		}
+		if err := m.fn(values); err != nil {
+			return fmt.Errorf("migrating from version %d: %w", version+i, err)
+		}
+	}
+	return nil
+}
+
+// moveCredentials migrates plaintext credentials from the config file into the encrypted secrets
+// location. Credentials that are already stored there take precedence and are kept.
+func moveCredentials(profile string, values map[string]string) error {
+	ds := UnflattenDialSettings(values)
+	for _, key := range credentialKeys {
+		delete(values, key)
+	}
+	if isEmptyCredentials(ds.Credentials) {
+		return nil
+	}
+
+	stored, err := ReadCredentials(profile)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		return nil
+	}
+	return WriteCredentials(profile, ds.Credentials)
+}
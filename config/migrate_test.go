@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/helpers"
+	"github.com/txsvc/cloudlib/settings"
+)
+
+// writeLegacyConfig writes a config file without a schema version and with plaintext credentials
+func writeLegacyConfig(t *testing.T) {
+	assert.NoError(t, helpers.WriteDialSettings(&settings.DialSettings{
+		Endpoint:    "https://api.example.com",
+		Credentials: &settings.Credentials{ClientID: "client", Token: "legacy-token"},
+		Options:     map[string]string{OptionAPIKey: "key"},
+	}, configFile(t)))
+}
+
+func TestMigrateConfig(t *testing.T) {
+	setupSecrets(t)
+	writeLegacyConfig(t)
+
+	// nothing changes with dry-run
+	steps, err := MigrateConfig(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(steps))
+	_, err = os.Stat(BackupFile(configFile(t), 1))
+	assert.True(t, os.IsNotExist(err))
+
+	steps, err = MigrateConfig(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(steps))
+
+	// the original is kept, without the token
+	data, err := os.ReadFile(BackupFile(configFile(t), 1))
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "legacy-token"))
+	assert.True(t, strings.Contains(string(data), "api.example.com"))
+
+	// the credentials were moved
+	cred, err := ReadCredentials(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy-token", cred.Token)
+
+	// the migrated file has a version and no credentials
+	f, err := readFileSettings(configFile(t))
+	assert.NoError(t, err)
+	assert.Equal(t, CurrentSchemaVersion(), f.Version)
+	assert.Nil(t, f.Credentials)
+
+	ds := GetConfig().Settings()
+	assert.Equal(t, "legacy-token", ds.Credentials.Token)
+	assert.Equal(t, "key", ds.GetOption(OptionAPIKey))
+
+	// up to date
+	steps, err = MigrateConfig(false)
+	assert.NoError(t, err)
+	assert.Empty(t, steps)
+}
+
+func TestMigrationOnLoad(t *testing.T) {
+	setupSecrets(t)
+	writeLegacyConfig(t)
+
+	from := CurrentSchemaVersion()
+	RegisterMigration(from, "rename the endpoint", func(values map[string]string) error {
+		values[KeyEndpoint] = strings.Replace(values[KeyEndpoint], "api.", "api2.", 1)
+		return nil
+	})
+	defer func() {
+		mmu.Lock()
+		delete(migrations, from)
+		mmu.Unlock()
+	}()
+	reset()
+
+	// older files are migrated in memory
+	ds := GetConfig().Settings()
+	assert.NoError(t, SettingsError())
+	assert.Equal(t, "https://api2.example.com", ds.Endpoint)
+	assert.Equal(t, "legacy-token", ds.Credentials.Token)
+
+	// but not rewritten
+	f, err := readFileSettings(configFile(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, f.Version)
+	assert.Equal(t, "https://api.example.com", f.Endpoint)
+	_, err = os.Stat(BackupFile(configFile(t), 1))
+	assert.True(t, os.IsNotExist(err))
+	cred, err := ReadCredentials(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Nil(t, cred)
+}
+
+func TestMigrateKeepsStoredCredentials(t *testing.T) {
+	setupSecrets(t)
+	assert.NoError(t, WriteCredentials(DefaultProfile, &settings.Credentials{ClientID: "client", Token: "stored-token"}))
+	writeLegacyConfig(t)
+
+	_, err := MigrateConfig(false)
+	assert.NoError(t, err)
+
+	cred, err := ReadCredentials(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "stored-token", cred.Token)
+
+	f, err := readFileSettings(configFile(t))
+	assert.NoError(t, err)
+	assert.Nil(t, f.Credentials)
+}
+
+func TestRegisterMigration(t *testing.T) {
+	setupSecrets(t)
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://api.example.com"}, configFile(t)))
+
+	from := CurrentSchemaVersion()
+	RegisterMigration(from, "rename the endpoint", func(values map[string]string) error {
+		values[KeyEndpoint] = strings.Replace(values[KeyEndpoint], "api.", "api2.", 1)
+		return nil
+	})
+	defer func() {
+		mmu.Lock()
+		delete(migrations, from)
+		mmu.Unlock()
+	}()
+	assert.Equal(t, from+1, CurrentSchemaVersion())
+
+	_, err := MigrateConfig(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api2.example.com", GetConfig().Settings().Endpoint)
+
+	// files from the future are not supported
+	assert.NoError(t, writeFileSettings(&fileSettings{Version: from + 2}, configFile(t)))
+	_, err = MigrateConfig(true)
+	assert.ErrorIs(t, err, ErrUnsupportedSchema)
+	reset()
+	assert.ErrorIs(t, SettingsError(), ErrUnsupportedSchema)
+}
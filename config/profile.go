@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+)
+
+// Profiles are named configurations, each with its own endpoint and credentials. The config
+// file in ConfigLocation() is the 'default' profile, all others live in 'profiles/<name>'.
+// The active profile is selected by SetProfile() (e.g. --profile), ENV['APIKIT_PROFILE']
+// or the profile stored with UseProfile(), in that order.
+
+const (
+	// DefaultProfile is the profile in the root of the config location
+	DefaultProfile = "default"
+	// ProfileENV selects the active profile
+	ProfileENV = "APIKIT_PROFILE"
+
+	profilesDir        = "profiles"
+	currentProfileFile = "profile"
+)
+
+var (
+	// ErrProfileNotFound indicates that the profile does not exist
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrProfileExists indicates that the profile already exists
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrInvalidProfileName indicates that the profile name contains invalid characters
+	ErrInvalidProfileName = errors.New("invalid profile name")
+
+	profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+	// the profile selected with SetProfile
+	selectedProfile string
+	pmu             sync.RWMutex // used to protect the above
+)
+
+// SetProfile explicitly selects the active profile, overriding ENV['APIKIT_PROFILE'] and the
+// stored profile. Passing "" removes the selection.
+func SetProfile(name string) {
+	pmu.Lock()
+	selectedProfile = name
+	pmu.Unlock()
+
+	reset()
+}
+
+// CurrentProfile returns the name of the active profile. It returns ErrInvalidProfileName or
+// ErrProfileNotFound if the selected profile can't be used.
+func CurrentProfile() (string, error) {
+	return currentProfileIn(GetConfig().ConfigLocation())
+}
+
+// ProfileLocation returns the directory of a profile's config file.
+func ProfileLocation(name string) string {
+	return profileDir(GetConfig().ConfigLocation(), name)
+}
+
+// Profiles returns the names of all profiles, sorted, starting with the default profile.
+func Profiles() ([]string, error) {
+	profiles := []string{DefaultProfile}
+
+	entries, err := os.ReadDir(filepath.Join(GetConfig().ConfigLocation(), profilesDir))
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	named := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() && validProfileName(e.Name()) && e.Name() != DefaultProfile {
+			named = append(named, e.Name())
+		}
+	}
+	sort.Strings(named)
+
+	return append(profiles, named...), nil
+}
+
+// UseProfile stores the profile as the active one.
+func UseProfile(name string) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	if !HasProfile(name) {
+		return ErrProfileNotFound
+	}
+
+	path := filepath.Join(GetConfig().ConfigLocation(), currentProfileFile)
+	if name == DefaultProfile {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(name+"\n"), configFilePerm); err != nil {
+			return err
+		}
+	}
+
+	reset()
+	return nil
+}
+
+// CreateProfile creates a new profile with the settings provided. The credentials are never
+// written to the config file, they are encrypted and stored separately, see WriteCredentials().
+func CreateProfile(name string, ds *settings.DialSettings) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	if !validProfileName(name) {
+		return ErrInvalidProfileName
+	}
+	if HasProfile(name) {
+		return ErrProfileExists
+	}
+
+	return saveSettingsIn(GetConfig().ConfigLocation(), name, ds)
+}
+
+// DeleteProfile removes a profile and all its files. The default profile can't be deleted.
+func DeleteProfile(name string) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	if name == DefaultProfile || !validProfileName(name) {
+		return ErrInvalidProfileName
+	}
+	if !HasProfile(name) {
+		return ErrProfileNotFound
+	}
+
+	// fall back to the default profile if the deleted one is stored as the active one
+	if storedProfile(GetConfig().ConfigLocation()) == name {
+		if err := UseProfile(DefaultProfile); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(ProfileLocation(name)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Dir(CredentialsFile(name))); err != nil {
+		return err
+	}
+
+	reset()
+	return nil
+}
+
+// currentProfileIn returns the active profile, never a name that could escape root
+func currentProfileIn(root string) (string, error) {
+	pmu.RLock()
+	name := selectedProfile
+	pmu.RUnlock()
+
+	if name == "" {
+		name = stdlib.GetString(ProfileENV, "")
+	}
+	if name == "" {
+		name = storedProfile(root)
+	}
+	if name == "" || name == DefaultProfile {
+		return DefaultProfile, nil
+	}
+
+	if !validProfileName(name) {
+		return "", fmt.Errorf("%w: '%s'", ErrInvalidProfileName, name)
+	}
+	if root == "" || !isDir(profileDir(root, name)) {
+		return "", fmt.Errorf("%w: '%s'", ErrProfileNotFound, name)
+	}
+	return name, nil
+}
+
+func storedProfile(root string) string {
+	if root == "" {
+		return "" // no files, e.g. with the environment-only config provider
+	}
+	data, err := os.ReadFile(filepath.Join(root, currentProfileFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func profileDir(root, name string) string {
+	if name == "" || name == DefaultProfile {
+		return root
+	}
+	return filepath.Join(root, profilesDir, name)
+}
+
+// HasProfile returns true if the profile exists.
+func HasProfile(name string) bool {
+	if name == DefaultProfile {
+		return true
+	}
+	return validProfileName(name) && isDir(ProfileLocation(name))
+}
+
+func validProfileName(name string) bool {
+	return profileNameRegex.MatchString(name)
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func TestProfiles(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+	t.Setenv(ProfileENV, "")
+
+	assertProfile(t, DefaultProfile)
+
+	profiles, err := Profiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{DefaultProfile}, profiles)
+
+	// create and use a profile
+	assert.NoError(t, CreateProfile("staging", &settings.DialSettings{Endpoint: "https://staging.example.com"}))
+	assert.Equal(t, ErrProfileExists, CreateProfile("staging", &settings.DialSettings{}))
+	assert.Equal(t, ErrInvalidProfileName, CreateProfile("../x", &settings.DialSettings{}))
+
+	assert.NoError(t, UseProfile("staging"))
+	assertProfile(t, "staging")
+	assert.Equal(t, "https://staging.example.com", GetConfig().Settings().Endpoint)
+
+	assert.Equal(t, ErrProfileNotFound, UseProfile("production"))
+
+	// explicit selection and ENV take precedence over the stored profile
+	assert.NoError(t, CreateProfile("production", &settings.DialSettings{Endpoint: "https://api.example.com"}))
+	t.Setenv(ProfileENV, "production")
+	assertProfile(t, "production")
+
+	SetProfile(DefaultProfile)
+	assertProfile(t, DefaultProfile)
+	assert.Equal(t, DefaultEndpoint, GetConfig().Settings().Endpoint)
+	SetProfile("")
+
+	profiles, err = Profiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{DefaultProfile, "production", "staging"}, profiles)
+
+	// delete the stored profile
+	t.Setenv(ProfileENV, "")
+	assert.NoError(t, DeleteProfile("staging"))
+	assertProfile(t, DefaultProfile)
+	assert.Equal(t, ErrInvalidProfileName, DeleteProfile(DefaultProfile))
+	assert.Equal(t, ErrProfileNotFound, DeleteProfile("staging"))
+}
+
+func TestProfileSelection(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	root := t.TempDir()
+	SetConfigLocation(root)
+	t.Setenv(ProfileENV, "")
+
+	assert.NoError(t, SaveSettings(&settings.DialSettings{Endpoint: "https://default.example.com"}))
+
+	// names that could escape the config location are rejected
+	t.Setenv(ProfileENV, "../x")
+	_, err := CurrentProfile()
+	assert.ErrorIs(t, err, ErrInvalidProfileName)
+	assert.ErrorIs(t, WriteCredentials("../x", &settings.Credentials{Token: "token"}), ErrInvalidProfileName)
+
+	// unknown profiles are an error, not the default profile
+	t.Setenv(ProfileENV, "production")
+	_, err = CurrentProfile()
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+	assert.Equal(t, DefaultEndpoint, GetConfig().Settings().Endpoint)
+	assert.ErrorIs(t, SettingsError(), ErrProfileNotFound)
+	assert.ErrorIs(t, SaveSettings(&settings.DialSettings{}), ErrProfileNotFound)
+
+	// the same applies to the stored profile
+	t.Setenv(ProfileENV, "")
+	assert.NoError(t, os.WriteFile(filepath.Join(root, currentProfileFile), []byte("../../etc\n"), configFilePerm))
+	reset()
+	_, err = CurrentProfile()
+	assert.ErrorIs(t, err, ErrInvalidProfileName)
+	_, err = StoredSettings()
+	assert.ErrorIs(t, err, ErrInvalidProfileName)
+	assert.Error(t, GetConfig().Validate())
+}
+
+func assertProfile(t *testing.T, expected string) {
+	profile, err := CurrentProfile()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, profile)
+}
+
+func configFile(t *testing.T) string {
+	path, err := ConfigFile()
+	assert.NoError(t, err)
+	return path
+}
@@ -0,0 +1,429 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/txsvc/cloudlib/settings"
+	"github.com/txsvc/stdlib/v2"
+)
+
+// Credentials are not stored in the config file but encrypted in SecretsLocation(), one file per
+// profile. The key is derived from a passphrase with scrypt and used with NaCl secretbox.
+// The passphrase is set with SetSecretsPassphrase(), ENV['APIKIT_SECRETS_PASSPHRASE'] (e.g. the
+// mnemonic from 'auth init') or read from a key file in SecretsLocation() that is created on
+// first use and stands in for an OS keyring.
+
+const (
+	SecretsLocationENV   = "SECRETS_LOCATION"          // where credentials are stored
+	SecretsPassphraseENV = "APIKIT_SECRETS_PASSPHRASE" // passphrase used to encrypt the credentials
+
+	// DefaultCredentialsName is the name of the encrypted credentials file
+	DefaultCredentialsName = "credentials"
+	// secretsKeyFile holds a random passphrase if none is provided
+	secretsKeyFile = "secrets.key"
+
+	secretsVersion = 1
+	secretsKDF     = "scrypt"
+
+	// scrypt parameters, see https://pkg.go.dev/golang.org/x/crypto/scrypt
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32
+	saltLength   = 16
+	nonceLength  = 24
+	randomLength = 32 // length of generated passphrases
+)
+
+type (
+	// secretsEnvelope is the encrypted credentials file
+	secretsEnvelope struct {
+		Version int    `json:"version"`
+		KDF     string `json:"kdf"`
+		Salt    string `json:"salt"`
+		Nonce   string `json:"nonce"`
+		Data    string `json:"data"`
+	}
+)
+
+var (
+	// ErrNoSecretsKey indicates that there is no passphrase to decrypt the credentials
+	ErrNoSecretsKey = errors.New("missing secrets passphrase")
+	// ErrDecryptingSecrets indicates that the credentials could not be decrypted, e.g. with the wrong passphrase
+	ErrDecryptingSecrets = errors.New("could not decrypt the credentials")
+
+	// the location set with SetSecretsLocation
+	secretsDir string
+	// the passphrase set with SetSecretsPassphrase
+	secretsPassphrase string
+	smu               sync.RWMutex // used to protect the above
+)
+
+// SetSecretsLocation explicitly sets the location of the encrypted credentials. Passing "" falls back
+// to ENV['SECRETS_LOCATION'] or the 'secrets' directory in the user's config directory.
+func SetSecretsLocation(loc string) {
+	smu.Lock()
+	secretsDir = loc
+	smu.Unlock()
+
+	reset()
+}
+
+// SecretsLocation returns the location of the encrypted credentials. Project-local credentials in
+// DefaultCredentialsLocation are used until they are moved, see MigrateLocations().
+func SecretsLocation() string {
+	smu.RLock()
+	defer smu.RUnlock()
+
+	if secretsDir != "" {
+		return secretsDir
+	}
+	if loc := stdlib.GetString(SecretsLocationENV, ""); loc != "" {
+		return loc
+	}
+
+	user := UserConfigLocation(shortNameOf(GetConfig().Info()))
+	if user == "" {
+		return DefaultCredentialsLocation
+	}
+	return searchLocation(DefaultCredentialsLocation, filepath.Join(user, secretsDirName))
+}
+
+// SetSecretsPassphrase explicitly sets the passphrase of the credentials, overriding
+// ENV['APIKIT_SECRETS_PASSPHRASE'] and the key file. Passing "" removes it.
+func SetSecretsPassphrase(passphrase string) {
+	smu.Lock()
+	secretsPassphrase = passphrase
+	smu.Unlock()
+
+	reset()
+}
+
+// CredentialsFile returns the encrypted credentials file of a profile.
+func CredentialsFile(profile string) string {
+	return filepath.Join(profileDir(SecretsLocation(), profile), DefaultCredentialsName)
+}
+
+// ReadCredentials decrypts the credentials of a profile. Missing credentials are not an error,
+// nil is returned instead.
+func ReadCredentials(profile string) (*settings.Credentials, error) {
+	if profile != DefaultProfile && !validProfileName(profile) {
+		return nil, ErrInvalidProfileName
+	}
+
+	data, err := os.ReadFile(CredentialsFile(profile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	return decryptCredentials(data, passphrase)
+}
+
+// WriteCredentials encrypts and stores the credentials of a profile. Empty credentials remove the
+// file, unless it can't be decrypted: the credentials were never read then, and are kept.
+func WriteCredentials(profile string, cred *settings.Credentials) error {
+	if profile != DefaultProfile && !validProfileName(profile) {
+		return ErrInvalidProfileName
+	}
+	path := CredentialsFile(profile)
+
+	if isEmptyCredentials(cred) {
+		if _, err := ReadCredentials(profile); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	passphrase, err := passphrase(true)
+	if err != nil {
+		return err
+	}
+	data, err := encryptCredentials(cred, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// RekeySecrets encrypts the credentials of all profiles with a new passphrase. If newPassphrase
+// is empty, a random one is generated and stored in the key file, otherwise the key file is
+// removed and the new passphrase has to be provided with ENV['APIKIT_SECRETS_PASSPHRASE'].
+// All files are written next to the old ones first and then renamed into place, the key file
+// last, so that a failure never leaves credentials behind that no passphrase can decrypt.
+func RekeySecrets(newPassphrase string) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	if newPassphrase == "" && stdlib.GetString(SecretsPassphraseENV, "") != "" {
+		return fmt.Errorf("%w: unset %s to use a key file", ErrInvalidConfiguration, SecretsPassphraseENV)
+	}
+
+	profiles, err := Profiles()
+	if err != nil {
+		return err
+	}
+
+	// decrypt everything with the current passphrase first
+	creds := make(map[string]*settings.Credentials)
+	for _, p := range profiles {
+		cred, err := ReadCredentials(p)
+		if err != nil {
+			return fmt.Errorf("profile '%s': %w", p, err)
+		}
+		if cred != nil {
+			creds[p] = cred
+		}
+	}
+
+	keyFile := filepath.Join(SecretsLocation(), secretsKeyFile)
+	randomKey := newPassphrase == ""
+	if randomKey {
+		if newPassphrase, err = randomPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	// stage everything, nothing is replaced yet
+	staged := make(map[string]string) // temp file -> target
+	defer func() {
+		for tmp := range staged {
+			os.Remove(tmp) // whatever was not renamed
+		}
+	}()
+	stage := func(path string, data []byte) error {
+		tmp, err := writeTempFile(path, data)
+		if err != nil {
+			return err
+		}
+		staged[tmp] = path
+		return nil
+	}
+
+	for p, cred := range creds {
+		data, err := encryptCredentials(cred, newPassphrase)
+		if err != nil {
+			return err
+		}
+		if err := stage(CredentialsFile(p), data); err != nil {
+			return err
+		}
+	}
+	keyTmp := ""
+	if randomKey {
+		if keyTmp, err = writeTempFile(keyFile, []byte(newPassphrase+"\n")); err != nil {
+			return err
+		}
+	}
+
+	// swap the credentials, then the key. Once the first file was swapped, the new key is needed.
+	for tmp, path := range staged {
+		if err := os.Rename(tmp, path); err != nil {
+			return rekeyFailed(keyTmp, err)
+		}
+		delete(staged, tmp)
+	}
+	if randomKey {
+		if err := os.Rename(keyTmp, keyFile); err != nil {
+			return rekeyFailed(keyTmp, err)
+		}
+	} else if err := os.Remove(keyFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	SetSecretsPassphrase(newPassphrase)
+	return nil
+}
+
+// rekeyFailed reports an error after some credentials were encrypted with the new passphrase
+func rekeyFailed(keyTmp string, err error) error {
+	if keyTmp == "" {
+		return fmt.Errorf("rekeying the credentials failed, use the new passphrase: %w", err)
+	}
+	return fmt.Errorf("rekeying the credentials failed, the new key is in '%s': %w", keyTmp, err)
+}
+
+// passphrase returns the passphrase to use. If create is true, a key file is created if there is no passphrase.
+func passphrase(create bool) (string, error) {
+	smu.RLock()
+	p := secretsPassphrase
+	smu.RUnlock()
+
+	if p != "" {
+		return p, nil
+	}
+	if p = stdlib.GetString(SecretsPassphraseENV, ""); p != "" {
+		return p, nil
+	}
+
+	keyFile := filepath.Join(SecretsLocation(), secretsKeyFile)
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	if !create {
+		return "", ErrNoSecretsKey
+	}
+
+	if p, err = randomPassphrase(); err != nil {
+		return "", err
+	}
+	return p, writeKeyFile(keyFile, p)
+}
+
+func encryptCredentials(cred *settings.Credentials, passphrase string) ([]byte, error) {
+	plain, err := json.Marshal(newFileSettings(&settings.DialSettings{Credentials: cred}).Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	var nonce [nonceLength]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(&secretsEnvelope{
+		Version: secretsVersion,
+		KDF:     secretsKDF,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce[:]),
+		Data:    base64.StdEncoding.EncodeToString(secretbox.Seal(nil, plain, &nonce, key)),
+	}, "", indentChar)
+}
+
+func decryptCredentials(data []byte, passphrase string) (*settings.Credentials, error) {
+	var env secretsEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	if env.Version != secretsVersion || env.KDF != secretsKDF {
+		return nil, fmt.Errorf("%w: unsupported version", ErrDecryptingSecrets)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(env.Salt)
+	if err != nil {
+		return nil, ErrDecryptingSecrets
+	}
+	n, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil || len(n) != nonceLength {
+		return nil, ErrDecryptingSecrets
+	}
+	box, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return nil, ErrDecryptingSecrets
+	}
+
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceLength]byte
+	copy(nonce[:], n)
+	plain, ok := secretbox.Open(nil, box, &nonce, key)
+	if !ok {
+		return nil, ErrDecryptingSecrets
+	}
+
+	f := fileSettings{}
+	if err := json.Unmarshal(plain, &f.Credentials); err != nil {
+		return nil, err
+	}
+	return f.dialSettings().Credentials, nil
+}
+
+func deriveKey(passphrase string, salt []byte) (*[keyLength]byte, error) {
+	k, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [keyLength]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+func randomPassphrase() (string, error) {
+	b := make([]byte, randomLength)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeKeyFile(path, passphrase string) error {
+	return writeFileAtomic(path, []byte(passphrase+"\n"))
+}
+
+// writeFileAtomic replaces a file with a new one, readers see either the old or the new content
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := writeTempFile(path, data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeTempFile writes data to a new file next to path and returns its name
+func writeTempFile(path string, data []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), configDirPerm); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), os.Chmod(f.Name(), configFilePerm)
+}
+
+func isEmptyCredentials(cred *settings.Credentials) bool {
+	return cred == nil || *cred == settings.Credentials{}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func setupSecrets(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+	SetSecretsLocation(t.TempDir())
+	t.Setenv(SecretsPassphraseENV, "")
+
+	t.Cleanup(func() {
+		SetSecretsLocation("")
+		SetSecretsPassphrase("")
+	})
+}
+
+func TestSaveCredentials(t *testing.T) {
+	setupSecrets(t)
+
+	ds := GetConfig().Settings().Clone()
+	ds.Credentials = &settings.Credentials{
+		ProjectID: "project",
+		ClientID:  "client",
+		Token:     "secret-token",
+		Status:    settings.StateAuthorized,
+	}
+	assert.NoError(t, SaveSettings(&ds))
+
+	// the token is not in plaintext anywhere
+	data, err := os.ReadFile(configFile(t))
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "secret-token"))
+
+	data, err = os.ReadFile(CredentialsFile(DefaultProfile))
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "secret-token"))
+
+	// a key file was created
+	_, err = os.Stat(filepath.Join(SecretsLocation(), secretsKeyFile))
+	assert.NoError(t, err)
+
+	// transparently decrypted
+	assert.Equal(t, "secret-token", GetConfig().Settings().Credentials.Token)
+	assert.Equal(t, settings.StateAuthorized, GetConfig().Settings().Credentials.Status)
+
+	// empty credentials remove the file
+	ds.Credentials = &settings.Credentials{}
+	assert.NoError(t, SaveSettings(&ds))
+	_, err = os.Stat(CredentialsFile(DefaultProfile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPlaintextCredentials(t *testing.T) {
+	setupSecrets(t)
+
+	// config files that still contain the credentials
+	ds := &settings.DialSettings{
+		Endpoint:    "https://api.example.com",
+		Credentials: &settings.Credentials{ClientID: "client", Token: "plain-token"},
+	}
+	assert.NoError(t, WriteDialSettings(ds, configFile(t)))
+	assert.Equal(t, "plain-token", GetConfig().Settings().Credentials.Token)
+
+	// move them into the secrets location
+	stored, err := StoredSettings()
+	assert.NoError(t, err)
+	assert.NoError(t, SaveSettings(stored))
+
+	data, err := os.ReadFile(configFile(t))
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "plain-token"))
+	assert.Equal(t, "plain-token", GetConfig().Settings().Credentials.Token)
+}
+
+func TestCreateProfileCredentials(t *testing.T) {
+	setupSecrets(t)
+
+	cred := &settings.Credentials{ClientID: "client", Token: "profile-token"}
+	assert.NoError(t, CreateProfile("staging", &settings.DialSettings{Credentials: cred}))
+
+	// the credentials are not in the config file
+	data, err := os.ReadFile(ConfigFileIn(ProfileLocation("staging")))
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), "profile-token"))
+
+	c, err := ReadCredentials("staging")
+	assert.NoError(t, err)
+	assert.Equal(t, cred, c)
+}
+
+func TestRekeySecrets(t *testing.T) {
+	setupSecrets(t)
+
+	cred := &settings.Credentials{ClientID: "client", Token: "token"}
+	assert.NoError(t, WriteCredentials(DefaultProfile, cred))
+	assert.NoError(t, CreateProfile("staging", &settings.DialSettings{}))
+	assert.NoError(t, WriteCredentials("staging", cred))
+
+	// rekey with a passphrase
+	assert.NoError(t, RekeySecrets("correct horse battery staple"))
+	_, err := os.Stat(filepath.Join(SecretsLocation(), secretsKeyFile))
+	assert.True(t, os.IsNotExist(err))
+
+	c, err := ReadCredentials("staging")
+	assert.NoError(t, err)
+	assert.Equal(t, cred, c)
+
+	// the passphrase is required
+	SetSecretsPassphrase("")
+	_, err = ReadCredentials(DefaultProfile)
+	assert.ErrorIs(t, err, ErrNoSecretsKey)
+
+	t.Setenv(SecretsPassphraseENV, "wrong")
+	_, err = ReadCredentials(DefaultProfile)
+	assert.ErrorIs(t, err, ErrDecryptingSecrets)
+
+	t.Setenv(SecretsPassphraseENV, "correct horse battery staple")
+	c, err = ReadCredentials(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, cred, c)
+
+	// back to a key file
+	assert.Error(t, RekeySecrets(""))
+	t.Setenv(SecretsPassphraseENV, "")
+	SetSecretsPassphrase("correct horse battery staple")
+	assert.NoError(t, RekeySecrets(""))
+	SetSecretsPassphrase("")
+
+	c, err = ReadCredentials(DefaultProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, cred, c)
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(CredentialsFile(DefaultProfile)))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, strings.HasPrefix(e.Name(), "."), e.Name())
+	}
+
+	// deleting a profile removes its credentials
+	assert.NoError(t, DeleteProfile("staging"))
+	_, err = os.Stat(CredentialsFile("staging"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUndecryptableCredentials(t *testing.T) {
+	setupSecrets(t)
+
+	ds := &settings.DialSettings{
+		Endpoint:      "https://api.example.com",
+		DefaultScopes: []string{"api:read"},
+		Credentials:   &settings.Credentials{ClientID: "client", Token: "token"},
+	}
+	SetSecretsPassphrase("correct horse battery staple")
+	assert.NoError(t, SaveSettings(ds))
+	SetSecretsPassphrase("")
+	t.Setenv(SecretsPassphraseENV, "wrong")
+
+	// only the credentials are missing
+	cfg := GetConfig().Settings()
+	assert.Equal(t, "https://api.example.com", cfg.Endpoint)
+	assert.Empty(t, cfg.Credentials.Token)
+	assert.ErrorIs(t, SettingsError(), ErrDecryptingSecrets)
+
+	var errs ValidationErrors
+	if assert.ErrorAs(t, Validate(), &errs) {
+		assert.Equal(t, "file", errs[0].Field)
+	}
+
+	// saving the settings without credentials does not remove them
+	assert.ErrorIs(t, SaveSettings(cfg), ErrDecryptingSecrets)
+	assert.ErrorIs(t, SetValue(KeyEndpoint, "https://other.example.com"), ErrDecryptingSecrets)
+
+	t.Setenv(SecretsPassphraseENV, "correct horse battery staple")
+	reset()
+	assert.NoError(t, SettingsError())
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+	assert.Equal(t, "token", GetConfig().Settings().Credentials.Token)
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/txsvc/cloudlib/settings"
+
+	"github.com/txsvc/apikit/auth"
+)
+
+const (
+	// OptionAPIKey is the option that holds the client's API key
+	OptionAPIKey = "APIKey"
+)
+
+type (
+	// ValidationError is a problem with a single setting, identified by its field path, e.g. 'scopes[1]'
+	ValidationError struct {
+		Field   string
+		Message string
+	}
+
+	// ValidationErrors are all problems found by Validate()
+	ValidationErrors []*ValidationError
+)
+
+var (
+	// all option keys known to the service
+	knownOptions = map[string]bool{
+		OptionAPIKey: true,
+	}
+	omu sync.RWMutex // used to protect the above
+)
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Unwrap makes errors.Is(err, ErrInvalidConfiguration) work
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidConfiguration
+}
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// RegisterOptions adds service specific option keys to the known options.
+func RegisterOptions(keys ...string) {
+	omu.Lock()
+	defer omu.Unlock()
+
+	for _, k := range keys {
+		knownOptions[k] = true
+	}
+}
+
+// IsKnownOption returns true if the option key is known, see RegisterOptions.
+func IsKnownOption(key string) bool {
+	omu.RLock()
+	defer omu.RUnlock()
+
+	return knownOptions[key]
+}
+
+// Validate checks the effective settings of the config provider.
+func Validate() error {
+	if config_ == nil {
+		return ErrMissingConfigurator
+	}
+	return config_.Validate()
+}
+
+// ValidateSettings checks the endpoint, scopes, credentials and options. All problems are
+// returned as ValidationErrors, nil means that the settings are valid.
+func ValidateSettings(ds *settings.DialSettings) error {
+	var errs ValidationErrors
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if ds == nil {
+		add("", "missing settings")
+		return errs
+	}
+
+	if msg := checkEndpoint(ds.Endpoint); msg != "" {
+		add(KeyEndpoint, msg)
+	}
+
+	for i, s := range ds.Scopes {
+		if !auth.IsKnownScope(s) {
+			add(fmt.Sprintf("%s[%d]", KeyScopes, i), "unknown scope '%s'", s)
+		}
+	}
+	for i, s := range ds.DefaultScopes {
+		if !auth.IsKnownScope(s) {
+			add(fmt.Sprintf("%s[%d]", KeyDefaultScopes, i), "unknown scope '%s'", s)
+		}
+	}
+
+	if c := ds.Credentials; c != nil {
+		switch c.Status {
+		case 0, settings.StateUndefined, settings.StateInvalid:
+			// nothing to check
+		case settings.StateInit:
+			if c.ProjectID == "" {
+				add(KeyProjectID, "required in state %d (init)", c.Status)
+			}
+			if c.ClientID == "" {
+				add(KeyClientID, "required in state %d (init)", c.Status)
+			}
+		case settings.StateAuthorized:
+			if c.ClientID == "" {
+				add(KeyClientID, "required in state %d (authorized)", c.Status)
+			}
+			if c.Token == "" && c.ClientSecret == "" {
+				add(KeyToken, "token or client secret required in state %d (authorized)", c.Status)
+			}
+			if c.Expires < 0 {
+				add(KeyExpires, "invalid credentials can't be authorized")
+			}
+		default:
+			add(KeyStatus, "unknown state %d", c.Status)
+		}
+	}
+
+	keys := make([]string, 0, len(ds.Options))
+	for k := range ds.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !IsKnownOption(k) {
+			add(KeyOptionPrefix+k, "unknown option")
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkEndpoint returns a message if the endpoint is not a http(s) URL
+func checkEndpoint(endpoint string) string {
+	if endpoint == "" {
+		return "missing endpoint"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be a http(s) URL"
+	}
+	return ""
+}
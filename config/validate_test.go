@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func TestValidateSettings(t *testing.T) {
+	ds := &settings.DialSettings{
+		Endpoint:      "http://localhost:8080",
+		DefaultScopes: defaultScopes(),
+		Credentials: &settings.Credentials{
+			ProjectID: "project",
+			ClientID:  "client",
+			Token:     "token",
+			Status:    settings.StateAuthorized,
+		},
+		Options: map[string]string{OptionAPIKey: "key"},
+	}
+	assert.NoError(t, ValidateSettings(ds))
+
+	ds.Endpoint = "localhost:8080"
+	ds.Scopes = []string{"api:read", "api:everything"}
+	ds.Credentials.Token = ""
+	ds.Options["color"] = "blue"
+
+	err := ValidateSettings(ds)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidConfiguration))
+
+	var errs ValidationErrors
+	assert.True(t, errors.As(err, &errs))
+
+	fields := make([]string, 0)
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{KeyEndpoint, "scopes[1]", KeyToken, "options.color"}, fields)
+
+	// registered options are valid
+	RegisterOptions("color")
+	ds.Endpoint = "https://api.example.com"
+	ds.Scopes = nil
+	ds.Credentials.Status = settings.StateUndefined
+	assert.NoError(t, ValidateSettings(ds))
+
+	ds.Credentials.Status = 42
+	assert.Error(t, ValidateSettings(ds))
+}
+
+func TestValidate(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+
+	// the defaults are valid
+	assert.NoError(t, Validate())
+
+	assert.NoError(t, SetValue(KeyScopes, "api:read,api:unknown"))
+	assert.Error(t, Validate())
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownKey indicates that a key does not name a setting that can be stored in the config file
+	ErrUnknownKey = errors.New("unknown setting")
+	// ErrInvalidValue indicates that a value is not valid for its setting
+	ErrInvalidValue = errors.New("invalid value")
+
+	// keys with values that should not be displayed
+	secretKeys = []string{KeyClientSecret, KeyToken, KeyOptionPrefix + OptionAPIKey}
+)
+
+// IsFileKey returns true if the key can be stored in the config file, i.e. one of the
+// DialSettings or server keys or an option like 'options.APIKey'.
+func IsFileKey(key string) bool {
+	if strings.HasPrefix(key, KeyOptionPrefix) {
+		return len(key) > len(KeyOptionPrefix)
+	}
+	for _, k := range settingKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSecretKey returns true if the value of the key should not be displayed.
+func IsSecretKey(key string) bool {
+	for _, k := range secretKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateValue checks that a value can be used for a setting.
+// Options have to be known, see RegisterOptions.
+func ValidateValue(key, value string) error {
+	if !IsFileKey(key) {
+		return fmt.Errorf("%w: '%s'", ErrUnknownKey, key)
+	}
+	if name, ok := strings.CutPrefix(key, KeyOptionPrefix); ok && !IsKnownOption(name) {
+		return fmt.Errorf("%w: '%s' is not a registered option", ErrUnknownKey, key)
+	}
+
+	switch key {
+	case KeyEndpoint:
+		if msg := checkEndpoint(value); msg != "" {
+			return fmt.Errorf("%w: %s %s", ErrInvalidValue, key, msg)
+		}
+	case KeyStatus, KeyExpires:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("%w: %s must be a number", ErrInvalidValue, key)
+		}
+	case KeyPort:
+		if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("%w: %s must be a port number", ErrInvalidValue, key)
+		}
+	case KeyShutdownDelay, KeyReadTimeout, KeyWriteTimeout:
+		if parseDuration(value, -1) < 0 {
+			return fmt.Errorf("%w: %s must be a duration, e.g. '30s'", ErrInvalidValue, key)
+		}
+	case KeyLogLevel:
+		switch strings.ToLower(value) {
+		case "debug", "info", "warn", "error", "off":
+		default:
+			return fmt.Errorf("%w: %s must be one of debug, info, warn, error or off", ErrInvalidValue, key)
+		}
+	}
+	return nil
+}
+
+// ValidateValues checks all values, e.g. after the config file was edited. The keys are checked
+// in sorted order, i.e. the same problem is reported first every time.
+func ValidateValues(values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := ValidateValue(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileValues returns the stored values of the active profile. A missing config file has no values.
+func FileValues() (map[string]string, error) {
+	profile, err := CurrentProfile()
+	if err != nil {
+		return nil, err
+	}
+	values, err := readStoredValues(GetConfig().ConfigLocation(), profile)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// SetValue validates a value and stores it in the config file of the active profile.
+func SetValue(key, value string) error {
+	if err := ValidateValue(key, value); err != nil {
+		return err
+	}
+
+	values, err := FileValues()
+	if err != nil {
+		return err
+	}
+	values[key] = value
+
+	return SaveValues(values)
+}
+
+// UnsetValue removes a value from the config file of the active profile. The setting then
+// falls back to its default, if there is one.
+func UnsetValue(key string) error {
+	if !IsFileKey(key) {
+		return fmt.Errorf("%w: '%s'", ErrUnknownKey, key)
+	}
+
+	values, err := FileValues()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[key]; !ok {
+		return nil // nothing to do
+	}
+	delete(values, key)
+
+	return SaveValues(values)
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func TestSetValue(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+	RegisterOptions("region")
+
+	values, err := FileValues()
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+
+	assert.NoError(t, SetValue(KeyEndpoint, "https://api.example.com"))
+	assert.NoError(t, SetValue(KeyScopes, "api:read, api:write"))
+	assert.NoError(t, SetValue(KeyOptionPrefix+"region", "eu"))
+
+	ds := GetConfig().Settings()
+	assert.Equal(t, "https://api.example.com", ds.Endpoint)
+	assert.Equal(t, []string{"api:read", "api:write"}, ds.Scopes)
+	assert.Equal(t, "eu", ds.GetOption("region"))
+
+	s, _ := Lookup(KeyEndpoint)
+	assert.Equal(t, SourceFile, s.Source)
+
+	// only what was set is stored
+	values, err = FileValues()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(values))
+
+	// back to the default
+	assert.NoError(t, UnsetValue(KeyEndpoint))
+	assert.Equal(t, DefaultEndpoint, GetConfig().Settings().Endpoint)
+	assert.NoError(t, UnsetValue(KeyEndpoint))
+
+	// invalid keys and values
+	assert.ErrorIs(t, SetValue("endpoints", "https://api.example.com"), ErrUnknownKey)
+	assert.ErrorIs(t, SetValue(KeyOptionPrefix, "x"), ErrUnknownKey)
+	assert.ErrorIs(t, SetValue(KeyOptionPrefix+"foo", "x"), ErrUnknownKey)
+	assert.ErrorIs(t, SetValue(KeyPort, "http"), ErrInvalidValue)
+	assert.ErrorIs(t, SetValue(KeyShutdownDelay, "soon"), ErrInvalidValue)
+	assert.ErrorIs(t, SetValue(KeyLogLevel, "verbose"), ErrInvalidValue)
+	assert.ErrorIs(t, SetValue(KeyEndpoint, "localhost"), ErrInvalidValue)
+	assert.ErrorIs(t, SetValue(KeyExpires, "tomorrow"), ErrInvalidValue)
+	assert.ErrorIs(t, UnsetValue("endpoints"), ErrUnknownKey)
+}
+
+func TestUnsetUnknownOption(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+
+	// e.g. an option that is no longer used
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Options: map[string]string{"foo": "x"}}, configFile(t)))
+	assert.Error(t, Validate())
+
+	assert.NoError(t, UnsetValue(KeyOptionPrefix+"foo"))
+	assert.NoError(t, Validate())
+}
+
+func TestServerValues(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+
+	assert.NoError(t, SetValue(KeyPort, "9090"))
+	assert.NoError(t, SetValue(KeyShutdownDelay, "5s"))
+	assert.NoError(t, SetValue(KeyLogLevel, "debug"))
+
+	srv := Server()
+	assert.Equal(t, "9090", srv.Port)
+	assert.Equal(t, 5*time.Second, srv.ShutdownDelay)
+	assert.Equal(t, "debug", srv.LogLevel)
+
+	s, _ := Lookup(KeyPort)
+	assert.Equal(t, SourceFile, s.Source)
+
+	// saving the dial settings keeps the server settings
+	assert.NoError(t, SaveSettings(&settings.DialSettings{Endpoint: "https://api.example.com"}))
+	assert.Equal(t, "9090", Server().Port)
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+
+	values, err := ReadFileValues(configFile(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "5s", values[KeyShutdownDelay])
+
+	assert.NoError(t, UnsetValue(KeyPort))
+	assert.Equal(t, "", Server().Port)
+}
+
+func TestValidateValues(t *testing.T) {
+	values := map[string]string{
+		KeyEndpoint:  "not a url",
+		KeyPort:      "0",
+		KeyLogLevel:  "loud",
+		KeyUserAgent: "test/1.0",
+	}
+
+	// always the first problem in key order
+	for i := 0; i < 10; i++ {
+		err := ValidateValues(values)
+		assert.ErrorIs(t, err, ErrInvalidValue)
+		assert.Contains(t, err.Error(), KeyEndpoint)
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+const (
+	// DefaultWatchInterval is used to poll for changes if file system notifications are not available
+	DefaultWatchInterval = 5 * time.Second
+
+	// wait for more events before reloading, editors often write a file in several steps
+	watchDebounce = 200 * time.Millisecond
+)
+
+type (
+	// ChangeFunc is called with the previous and the new settings after they were reloaded
+	ChangeFunc func(old, new *settings.DialSettings)
+
+	// subscriber wraps a ChangeFunc, func values can't be compared
+	subscriber struct {
+		fn ChangeFunc
+	}
+)
+
+var (
+	// subscribers registered with OnChange
+	subscribers []*subscriber
+	wmu         sync.RWMutex // used to protect the above
+)
+
+// OnChange registers a function that is called whenever the settings change, e.g. to update the
+// log level. Components that call Settings() on every use pick up changes without subscribing.
+// Call the returned function to unsubscribe.
+func OnChange(fn ChangeFunc) func() {
+	wmu.Lock()
+	defer wmu.Unlock()
+
+	sub := &subscriber{fn: fn}
+	subscribers = append(subscribers, sub)
+
+	return func() {
+		wmu.Lock()
+		defer wmu.Unlock()
+
+		for i, s := range subscribers {
+			if s == sub {
+				subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Reload resolves the settings again and notifies all subscribers if anything changed. If the new
+// settings are invalid, the current ones are kept and the error is returned.
+func Reload() error {
+	if config_ == nil {
+		return ErrMissingConfigurator
+	}
+
+	var prev, next *Layers
+	if r, ok := config_.(interface {
+		reload() (*Layers, *Layers, error)
+	}); ok {
+		var err error
+		if prev, next, err = r.reload(); err != nil {
+			return err
+		}
+	} else {
+		prev = currentLayers()
+		reset()
+		next = currentLayers()
+	}
+
+	if reflect.DeepEqual(prev.Values(), next.Values()) {
+		return nil // nothing changed
+	}
+
+	wmu.RLock()
+	subs := make([]*subscriber, len(subscribers))
+	copy(subs, subscribers)
+	wmu.RUnlock()
+
+	old, ds := prev.DialSettings(), next.DialSettings()
+	for _, sub := range subs {
+		sub.fn(old, ds)
+	}
+	return nil
+}
+
+// Watch reloads the settings whenever the files in ConfigLocation() or SecretsLocation() change,
+// until ctx is done. It uses file system notifications and falls back to polling the files every
+// interval if notifications are not available. The watched directories follow changes of the
+// active profile and the config location, they are checked every interval.
+func Watch(ctx context.Context, interval time.Duration) {
+	if checkWritable() != nil {
+		return // nothing to watch, use Reload() to read the environment again
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		if watched := syncWatched(w, nil); len(watched) > 0 {
+			go notifyLoop(ctx, w, watched, interval)
+			return
+		}
+		w.Close()
+	}
+
+	// fall back to polling
+	go pollLoop(ctx, interval)
+}
+
+func notifyLoop(ctx context.Context, w *fsnotify.Watcher, watched []string, interval time.Duration) {
+	defer w.Close()
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	// SetProfile() and SetConfigLocation() don't touch any files
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			debounce.Reset(watchDebounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("error watching the configuration: %v", err)
+		case <-debounce.C:
+			watched = syncWatched(w, watched) // e.g. after 'config profiles use'
+			reload()
+		case <-ticker.C:
+			if current := syncWatched(w, watched); !reflect.DeepEqual(watched, current) {
+				watched = current
+				reload()
+			}
+		}
+	}
+}
+
+// syncWatched watches the directories of the active profile and stops watching the ones that
+// are no longer used. It returns the directories that are watched.
+func syncWatched(w *fsnotify.Watcher, watched []string) []string {
+	dirs := watchedDirs()
+
+	used := make(map[string]bool)
+	for _, d := range dirs {
+		used[d] = true
+	}
+	for _, d := range watched {
+		if !used[d] {
+			w.Remove(d)
+		}
+	}
+
+	current := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		if w.Add(d) == nil { // directories that don't exist yet are tried again
+			current = append(current, d)
+		}
+	}
+	return current
+}
+
+func pollLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := snapshot(); !reflect.DeepEqual(last, current) {
+				last = current
+				reload()
+			}
+		}
+	}
+}
+
+func reload() {
+	if err := Reload(); err != nil {
+		log.Printf("error reloading the configuration: %v", err)
+	}
+}
+
+// watchedDirs returns the directories that contain the files of the active profile
+func watchedDirs() []string {
+	root := GetConfig().ConfigLocation()
+
+	// watch the root only until the active profile can be used
+	dirs := []string{root}
+	if profile, err := CurrentProfile(); err == nil {
+		dirs = append(dirs, profileDir(root, profile), filepath.Dir(CredentialsFile(profile)))
+	}
+
+	unique := make([]string, 0, len(dirs))
+	seen := make(map[string]bool)
+	for _, d := range dirs {
+		if !seen[d] {
+			seen[d] = true
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
+
+// snapshot returns the modification time and size of the files of the active profile
+func snapshot() map[string]string {
+	files := []string{filepath.Join(GetConfig().ConfigLocation(), currentProfileFile)}
+	if profile, err := CurrentProfile(); err == nil {
+		files = append(files, ConfigFileIn(ProfileLocation(profile)), CredentialsFile(profile))
+	}
+
+	s := make(map[string]string)
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			s[f] = fmt.Sprintf("%d/%d", fi.ModTime().UnixNano(), fi.Size())
+		}
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/txsvc/cloudlib/settings"
+)
+
+func TestReload(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+
+	var calls atomic.Int32
+	var endpoint atomic.Value
+	unsubscribe := OnChange(func(old, new *settings.DialSettings) {
+		calls.Add(1)
+		endpoint.Store(new.Endpoint)
+	})
+
+	assert.Equal(t, DefaultEndpoint, GetConfig().Settings().Endpoint)
+
+	// nothing changed
+	assert.NoError(t, Reload())
+	assert.Equal(t, int32(0), calls.Load())
+
+	// change the file behind the provider's back
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://api.example.com"}, configFile(t)))
+	assert.Equal(t, DefaultEndpoint, GetConfig().Settings().Endpoint)
+
+	assert.NoError(t, Reload())
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, "https://api.example.com", endpoint.Load())
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+
+	// invalid settings are not applied
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "api.example.com"}, configFile(t)))
+	assert.Error(t, Reload())
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+
+	// no more calls after unsubscribing
+	unsubscribe()
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://staging.example.com"}, configFile(t)))
+	assert.NoError(t, Reload())
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWatch(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://api.example.com"}, configFile(t)))
+	assert.Equal(t, "https://api.example.com", GetConfig().Settings().Endpoint)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	Watch(ctx, 50*time.Millisecond)
+
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://staging.example.com"}, configFile(t)))
+	assert.Eventually(t, func() bool {
+		return GetConfig().Settings().Endpoint == "https://staging.example.com"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestWatchProfileChange(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+	t.Setenv(ProfileENV, "")
+	defer SetProfile("")
+	assert.NoError(t, CreateProfile("staging", &settings.DialSettings{Endpoint: "https://staging.example.com"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	Watch(ctx, 50*time.Millisecond)
+
+	// the files of the new profile are watched
+	SetProfile("staging")
+	assert.Equal(t, "https://staging.example.com", GetConfig().Settings().Endpoint)
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://api.example.com"}, ConfigFileIn(ProfileLocation("staging"))))
+	assert.Eventually(t, func() bool {
+		return GetConfig().Settings().Endpoint == "https://api.example.com"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestPollForChanges(t *testing.T) {
+	SetProvider(NewLocalConfigProvider())
+	SetConfigLocation(t.TempDir())
+	assert.Equal(t, DefaultEndpoint, GetConfig().Settings().Endpoint)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pollLoop(ctx, 50*time.Millisecond)
+
+	// the config location does not exist yet
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, WriteDialSettings(&settings.DialSettings{Endpoint: "https://api.example.com"}, configFile(t)))
+	assert.Eventually(t, func() bool {
+		return GetConfig().Settings().Endpoint == "https://api.example.com"
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -15,12 +13,10 @@ import (
 	"github.com/txsvc/apikit/api"
 	"github.com/txsvc/apikit/auth"
 	"github.com/txsvc/apikit/config"
-	"github.com/txsvc/cloudlib/helpers"
-	"github.com/txsvc/cloudlib/settings"
 )
 
-// the below version numbers should match the git release tags,
-// i.e. there should be e.g. a version 'v0.1.0' on branch main !
+// the below version numbers are used if the build has no version, see config.InfoFromBuild().
+// They should match the git release tags, i.e. there should be e.g. a version 'v0.1.0' on branch main !
 const (
 	// MajorVersion of the API
 	majorVersion = 0
@@ -30,19 +26,10 @@ const (
 	fixVersion = 0
 )
 
-type (
-	appConfig struct {
-		// the interface to implement
-		config.ConfigProvider
-
-		// some implementation specifc data
-		info *config.Info
-		ds   *settings.DialSettings
-	}
-)
-
-func NewAppEngineConfigProvider() config.ConfigProvider {
-	info := config.NewAppInfo(
+func init() {
+	// App Engine has a read-only file system, the configuration comes from the environment only,
+	// e.g. APIKIT_ENDPOINT, APIKIT_SCOPES, APIKIT_CREDENTIALS_* or APIKIT_OPTIONS_*
+	config.SetProvider(config.NewEnvConfigProvider(config.InfoFromBuild(
 		"appengine kit",
 		"aek",
 		"Copyright 2022, transformative.services, https://txs.vc",
@@ -50,28 +37,7 @@ func NewAppEngineConfigProvider() config.ConfigProvider {
 		majorVersion,
 		minorVersion,
 		fixVersion,
-	)
-
-	return &appConfig{
-		info: &info,
-	}
-}
-
-func init() {
-	// initialize the config provider
-	config.SetProvider(config.NewLocalConfigProvider())
-
-	// create a default configuration for the service (if none exists)
-	path := filepath.Join(config.GetConfig().ConfigLocation(), config.DefaultConfigName)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		os.MkdirAll(filepath.Dir(path), os.ModePerm)
-
-		// create credentials and keys with defaults from this config provider
-		cfg := config.GetConfig().Settings()
-
-		// save the new configuration
-		helpers.WriteDialSettings(cfg, path)
-	}
+	)))
 }
 
 func main() {
@@ -97,6 +63,7 @@ func setup() *echo.Echo {
 	// add your endpoints here
 	e.GET("/", api.DefaultEndpoint)
 	e.GET("/ping", pingEndpoint)
+	e = api.WithVersionEndpoint(e)
 
 	// done
 	return e
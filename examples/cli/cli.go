@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"sort"
 
@@ -11,7 +10,6 @@ import (
 	"github.com/txsvc/apikit/api"
 	kit "github.com/txsvc/apikit/cli"
 	"github.com/txsvc/apikit/config"
-	"github.com/txsvc/cloudlib/observer"
 )
 
 func init() {
@@ -28,19 +26,21 @@ func main() {
 		Copyright: cfg.Info().Copyright(),
 		Commands:  setupCommands(),
 		Flags:     setupFlags(),
-		Before: func(c *cli.Context) error {
-			// handle global config
-			if path := c.String("config"); path != "" {
-				config.SetConfigLocation(path)
-			}
-			return nil
-		},
+		Before:    kit.HandleGlobalFlags,
+		// prints errors with a hint and exits with a matching exit code
+		ExitErrHandler: kit.ExitErrHandler,
+		// required by the completion scripts
+		EnableBashCompletion: true,
 	}
 	sort.Sort(cli.FlagsByName(app.Flags))
 
+	// executables named '<shortname>-<command>' run as commands too
+	app.Action = kit.PluginAction(cfg.Info().ShortName(), app.Commands)
+
 	// run the CLI
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+		// errors not handled by ExitErrHandler, e.g. unknown flags
+		os.Exit(kit.ExitCode(err))
 	}
 }
 
@@ -60,7 +60,10 @@ func setupCommands() []*cli.Command {
 	}
 
 	// merge with default commands
-	return kit.MergeCommands(cmds, kit.WithAuthCommands())
+	cmds = kit.MergeCommands(cmds, kit.WithAuthCommands(), kit.WithConfigCommands(), kit.WithSecretsCommands(), kit.WithAPICommands(), kit.WithVersionCommands(), kit.WithCompletionCommands(), kit.WithDocsCommands())
+
+	// manage the executables named '<shortname>-<command>', see PluginAction
+	return kit.MergeCommands(cmds, kit.WithPluginCommands(config.GetConfig().Info().ShortName(), cmds))
 }
 
 // setupCommands returns all global CLI flags and some default ones
@@ -89,12 +92,9 @@ func PingCmd(c *cli.Context) error {
 	}
 
 	var so api.StatusObject
-	if status, err := cl.GET("/ping", &so); err != nil {
-		observer.LogWithLevel(observer.LevelError, fmt.Sprintf("status: %d: %s", status, err))
-		return nil
+	if _, err := cl.GET("/ping", &so); err != nil {
+		return err // ExitErrHandler prints it with a hint and the matching exit code
 	}
 
-	observer.Log(fmt.Sprintf("%v\n", so))
-
-	return nil
+	return kit.Output(c, &so)
 }
@@ -7,13 +7,10 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
-	"github.com/txsvc/cloudlib/helpers"
-
 	"github.com/txsvc/apikit"
 	"github.com/txsvc/apikit/api"
 	"github.com/txsvc/apikit/auth"
@@ -25,15 +22,16 @@ func init() {
 	config.SetProvider(config.NewLocalConfigProvider())
 
 	// create a default configuration for the service (if none exists)
-	path := filepath.Join(config.GetConfig().ConfigLocation(), config.DefaultConfigName)
+	path, err := config.ConfigFile()
+	if err != nil {
+		log.Fatal(err)
+	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		os.MkdirAll(filepath.Dir(path), os.ModePerm)
-
 		// create credentials and keys with defaults from this config provider
 		cfg := config.GetConfig().Settings()
 
 		// save the new configuration
-		helpers.WriteDialSettings(cfg, path)
+		config.SaveSettings(cfg)
 	}
 }
 
@@ -66,6 +64,8 @@ func setup() *echo.Echo {
 
 	// add common endpoints
 	e = api.WithAuthEndpoints(e)
+	e = api.WithSessionEndpoints(e)
+	e = api.WithVersionEndpoint(e)
 
 	// add your own endpoints here
 	e.GET("/", api.DefaultEndpoint)
@@ -1,7 +1,9 @@
 package apikit
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,61 +14,124 @@ import (
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 
-	"github.com/txsvc/stdlib/v2"
-
+	"github.com/txsvc/apikit/auth"
 	"github.com/txsvc/apikit/config"
 )
 
 func (a *App) Listen(addr string) {
-	a.listen(addr, "", "", false)
+	a.listen(addr, "", "", "", false)
 }
 
 func (a *App) ListenAutoTLS(addr string) {
-	a.listen(addr, "", "", true)
+	a.listen(addr, "", "", "", true)
 }
 
 func (a *App) ListenTLS(addr, certFile, keyFile string) {
-	a.listen(addr, certFile, keyFile, true)
+	a.listen(addr, certFile, keyFile, "", true)
+}
+
+// ListenMutualTLS requires clients to present a certificate signed by one of the CAs in clientCAFile.
+// Certificates that are mapped to a registered client (see auth.RegisterClientCertificate) authenticate
+// the request, without the need for a bearer token.
+func (a *App) ListenMutualTLS(addr, certFile, keyFile, clientCAFile string) {
+	a.listen(addr, certFile, keyFile, clientCAFile, true)
 }
 
-func (a *App) listen(addr, certFile, keyFile string, useTLS bool) {
+func (a *App) listen(addr, certFile, keyFile, clientCAFile string, useTLS bool) {
+	// watch the configuration for changes
+	ctx, cancel := context.WithCancel(context.Background())
+	config.Watch(ctx, config.DefaultWatchInterval)
+
 	// setup shutdown handling
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-quit
+		cancel()
 		a.Stop()
 	}()
 
+	// reload the configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := config.Reload(); err != nil {
+				log.Printf("error reloading the configuration: %v", err)
+			}
+		}
+	}()
+
+	// an explicitly configured port takes precedence over addr
+	srv := config.Server()
+
 	if useTLS {
-		port := fmt.Sprintf(":%s", takeOne(stdlib.GetString(config.PortENV, addr), PORT_DEFAULT_TLS))
+		port := fmt.Sprintf(":%s", takeOne(takeOne(srv.Port, addr), PORT_DEFAULT_TLS))
 		certDir := fmt.Sprintf("%s/.cert", a.root)
 
-		autoTLSManager := autocert.Manager{
-			Prompt: autocert.AcceptTOS,
-			// Cache certificates to avoid issues with rate limits (https://letsencrypt.org/docs/rate-limits)
-			Cache: autocert.DirCache(certDir),
-			//HostPolicy: autocert.HostWhitelist("<DOMAIN>"),
+		var tlsc tls.Config
+		if certFile != "" && keyFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tlsc.Certificates = []tls.Certificate{cert}
+		} else {
+			autoTLSManager := autocert.Manager{
+				Prompt: autocert.AcceptTOS,
+				// Cache certificates to avoid issues with rate limits (https://letsencrypt.org/docs/rate-limits)
+				Cache: autocert.DirCache(certDir),
+				//HostPolicy: autocert.HostWhitelist("<DOMAIN>"),
+			}
+			tlsc.GetCertificate = autoTLSManager.GetCertificate
+			tlsc.NextProtos = []string{acme.ALPNProto}
 		}
-		tlsc := tls.Config{
-			GetCertificate: autoTLSManager.GetCertificate,
-			NextProtos:     []string{acme.ALPNProto},
+
+		if clientCAFile != "" {
+			pool, err := loadCertPool(clientCAFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tlsc.ClientCAs = pool
+			tlsc.ClientAuth = tls.RequireAndVerifyClientCert
+
+			// map client certificates to registered clients
+			a.svc.Use(auth.ClientCertificateMiddleware())
 		}
+
 		s := http.Server{
-			Addr:      port,
-			Handler:   a.svc, // set Echo as handler
-			TLSConfig: &tlsc,
+			Addr:         port,
+			Handler:      a.svc, // set Echo as handler
+			TLSConfig:    &tlsc,
+			ReadTimeout:  srv.ReadTimeout,
+			WriteTimeout: srv.WriteTimeout,
 		}
 		if err := s.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	} else {
 		// simply startup without TLS
-		port := fmt.Sprintf(":%s", takeOne(stdlib.GetString(config.PortENV, addr), PORT_DEFAULT))
+		port := fmt.Sprintf(":%s", takeOne(takeOne(srv.Port, addr), PORT_DEFAULT))
+		a.svc.Server.ReadTimeout = srv.ReadTimeout
+		a.svc.Server.WriteTimeout = srv.WriteTimeout
 		log.Fatal(a.svc.Start(port))
 	}
 }
 
+// loadCertPool reads a bundle of PEM encoded certificates
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, config.ErrInvalidConfiguration
+	}
+	return pool, nil
+}
+
 // takeOne returns valid string if not empty or later one.
 func takeOne(valid, or string) string {
 	if len(valid) > 0 {